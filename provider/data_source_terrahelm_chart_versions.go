@@ -0,0 +1,225 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// chartVersion represents the chart entry of the repository 'index.yaml'
+type chartVersion struct {
+	Version    string `yaml:"version"`
+	AppVersion string `yaml:"appVersion"`
+}
+
+func dataSourceHelmChartVersions() *schema.Resource {
+	return &schema.Resource{
+		Description: "Available Helm chart versions from the chart repository index",
+		ReadContext: dataSourceHelmChartVersionsRead,
+		Schema: map[string]*schema.Schema{
+			"repository_url": {
+				Description: "URL of the Helm chart repository",
+				Type:        schema.TypeString,
+				Required:    true,
+			},
+			"chart": {
+				Description: "Name of the Helm chart",
+				Type:        schema.TypeString,
+				Required:    true,
+			},
+			"repository_username": {
+				Description: "Username for the chart repository authentication",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"repository_password": {
+				Description: "Password for the chart repository authentication",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+			},
+			"insecure": {
+				Description: "Disable checking certificates (not safe)",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"versions": {
+				Description: "The available chart versions sorted from the newest to the oldest one",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"version": {
+							Description: "The version of the chart",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"app_version": {
+							Description: "The version of the app the chart contains",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceHelmChartVersionsRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	repositoryURL := strings.TrimSuffix(d.Get("repository_url").(string), "/")
+	chart := d.Get("chart").(string)
+	username := d.Get("repository_username").(string)
+	password := d.Get("repository_password").(string)
+	insecure := d.Get("insecure").(bool)
+
+	config := m.(*ProviderConfig)
+
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if insecure {
+		httpClient = insecureHTTPClient(httpClient)
+	}
+
+	indexURL := repositoryURL + "/index.yaml"
+	indexPath := filepath.Join(config.CacheDir, "index", generateHash(repositoryURL), "index.yaml")
+	migrateLegacyCache(ctx, filepath.Join(config.CacheDir, "index", truncatedHash(repositoryURL, legacyCacheHashLength)), filepath.Dir(indexPath))
+
+	var diags diag.Diagnostics
+	if config.Offline {
+		tflog.Info(ctx, fmt.Sprintf("Using the cached chart repository index in the offline mode: '%s'", indexPath))
+		if _, err := os.Stat(indexPath); err != nil {
+			return diag.Errorf("chart repository index of '%s' isn't cached at '%s' in the offline mode", repositoryURL, indexPath)
+		}
+	} else if cacheFresh(indexPath, config.IndexCacheTTL) {
+		tflog.Info(ctx, fmt.Sprintf("Using the fresh cached chart repository index: '%s'", indexPath))
+	} else {
+		tflog.Info(ctx, fmt.Sprintf("Chart repository index downloading: '%s' to '%s'...", indexURL, indexPath))
+		if err := downloadRepositoryIndex(httpClient, indexURL, indexPath, username, password); err != nil {
+			if _, statErr := os.Stat(indexPath); statErr != nil {
+				return diag.FromErr(err)
+			}
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "Using the cached chart repository index",
+				Detail:   fmt.Sprintf("Failed to download the chart repository index, the cached one is used: %s", err),
+			})
+		}
+	}
+
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		return append(diags, diag.FromErr(fmt.Errorf("failed to read the chart repository index: %s", err))...)
+	}
+
+	versions, err := parseChartVersions(data, chart)
+	if err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	var versionsList []interface{}
+	for _, v := range versions {
+		versionsList = append(versionsList, map[string]interface{}{
+			"version":     v.Version,
+			"app_version": v.AppVersion,
+		})
+	}
+	if err := d.Set("versions", versionsList); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	d.SetId(fmt.Sprintf("%s/%s", repositoryURL, chart))
+
+	return diags
+}
+
+// migrateLegacyCache moves the cache named with the legacy hash to the current path, so the cache of the earlier provider versions is kept
+func migrateLegacyCache(ctx context.Context, legacyPath, path string) {
+	if _, err := os.Stat(path); err == nil {
+		return
+	}
+	if _, err := os.Stat(legacyPath); err != nil {
+		return
+	}
+
+	tflog.Debug(ctx, fmt.Sprintf("Migrating the legacy cache: '%s' to '%s'...", legacyPath, path))
+	if err := os.Rename(legacyPath, path); err != nil {
+		tflog.Warn(ctx, fmt.Sprintf("Failed to migrate the legacy cache '%s': %s", legacyPath, err))
+	}
+}
+
+// downloadRepositoryIndex downloads the chart repository index, the basic auth is used if the username is set
+func downloadRepositoryIndex(client *http.Client, indexURL, destPath, username, password string) error {
+	req, err := http.NewRequest(http.MethodGet, indexURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create the chart repository index request: %v", err)
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download the chart repository index: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download the chart repository index: HTTP status %v", resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read the chart repository index: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destPath), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create the chart repository index directory: %v", err)
+	}
+	if err := os.WriteFile(destPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to save the chart repository index: %v", err)
+	}
+
+	return nil
+}
+
+// parseChartVersions returns the chart versions from the repository index sorted from the newest to the oldest one
+func parseChartVersions(data []byte, chart string) ([]chartVersion, error) {
+	var index struct {
+		Entries map[string][]chartVersion `yaml:"entries"`
+	}
+	if err := yaml.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse the chart repository index: %s", err)
+	}
+
+	versions, ok := index.Entries[chart]
+	if !ok {
+		return nil, fmt.Errorf("chart '%s' is not found in the chart repository index", chart)
+	}
+
+	// Non-semver versions go last
+	sort.SliceStable(versions, func(i, j int) bool {
+		vi, errI := version.NewVersion(versions[i].Version)
+		vj, errJ := version.NewVersion(versions[j].Version)
+		if errI != nil || errJ != nil {
+			return errI == nil && errJ != nil
+		}
+		return vi.GreaterThan(vj)
+	})
+
+	return versions, nil
+}
@@ -0,0 +1,189 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const testRepositoryIndex = `apiVersion: v1
+entries:
+  nginx:
+  - name: nginx
+    version: 15.9.0
+    appVersion: 1.25.3
+    urls:
+    - https://charts.example.com/nginx-15.9.0.tgz
+  - name: nginx
+    version: 15.10.1
+    appVersion: 1.25.4
+  - name: nginx
+    version: 16.0.0-rc.1
+    appVersion: 1.25.4
+  - name: nginx
+    version: 15.10.0
+    appVersion: 1.25.4
+  redis:
+  - name: redis
+    version: 18.0.0
+    appVersion: 7.2.0
+generated: "2024-03-01T10:00:00Z"
+`
+
+// TestParseChartVersions tests the parseChartVersions function
+func TestParseChartVersions(t *testing.T) {
+	versions, err := parseChartVersions([]byte(testRepositoryIndex), "nginx")
+	if err != nil {
+		t.Fatalf("parseChartVersions failed: %v", err)
+	}
+
+	expected := []chartVersion{
+		{"16.0.0-rc.1", "1.25.4"},
+		{"15.10.1", "1.25.4"},
+		{"15.10.0", "1.25.4"},
+		{"15.9.0", "1.25.3"},
+	}
+	if !reflect.DeepEqual(versions, expected) {
+		t.Errorf("unexpected chart versions: %v", versions)
+	}
+
+	if _, err := parseChartVersions([]byte(testRepositoryIndex), "missing"); err == nil {
+		t.Errorf("expected missing chart to fail")
+	}
+}
+
+// TestDataSourceHelmChartVersionsRead tests the dataSourceHelmChartVersionsRead function with the basic auth
+func TestDataSourceHelmChartVersionsRead(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "user" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/charts/index.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(testRepositoryIndex))
+	}))
+	defer ts.Close()
+
+	cfg := MockProviderConfig()
+	cfg.CacheDir = t.TempDir()
+
+	d := schema.TestResourceDataRaw(t, dataSourceHelmChartVersions().Schema, map[string]interface{}{
+		"repository_url":      ts.URL + "/charts/",
+		"chart":               "redis",
+		"repository_username": "user",
+		"repository_password": "secret",
+	})
+	if diags := dataSourceHelmChartVersionsRead(context.Background(), d, cfg); diags.HasError() {
+		t.Fatalf("dataSourceHelmChartVersionsRead failed: %v", diags)
+	}
+
+	if v := d.Get("versions.0.version"); v != "18.0.0" {
+		t.Errorf("unexpected chart version: %s", v)
+	}
+	if v := d.Get("versions.0.app_version"); v != "7.2.0" {
+		t.Errorf("unexpected chart app version: %s", v)
+	}
+
+	// The cached index is used when the repository is unavailable
+	d.Set("repository_password", "wrong")
+	diags := dataSourceHelmChartVersionsRead(context.Background(), d, cfg)
+	if diags.HasError() || len(diags) != 1 {
+		t.Fatalf("expected the cached index warning: %v", diags)
+	}
+
+	d.Set("repository_url", ts.URL+"/other")
+	if diags := dataSourceHelmChartVersionsRead(context.Background(), d, cfg); !diags.HasError() {
+		t.Errorf("expected uncached unavailable repository to fail")
+	}
+
+	// Only the cached index is used in the offline mode
+	cfg.Offline = true
+	if diags := dataSourceHelmChartVersionsRead(context.Background(), d, cfg); !diags.HasError() {
+		t.Errorf("expected uncached repository to fail in the offline mode")
+	}
+	d.Set("repository_url", ts.URL+"/charts/")
+	ts.Close()
+	if diags := dataSourceHelmChartVersionsRead(context.Background(), d, cfg); diags.HasError() || len(diags) != 0 {
+		t.Errorf("expected the cached index to be used without warnings in the offline mode: %v", diags)
+	}
+}
+
+// TestDataSourceHelmChartVersionsReadIndexCacheTTL tests that the cached index is reused within the TTL
+func TestDataSourceHelmChartVersionsReadIndexCacheTTL(t *testing.T) {
+	var downloads int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		w.Write([]byte(testRepositoryIndex))
+	}))
+	defer ts.Close()
+
+	cfg := MockProviderConfig()
+	cfg.CacheDir = t.TempDir()
+	cfg.IndexCacheTTL = time.Hour
+
+	d := schema.TestResourceDataRaw(t, dataSourceHelmChartVersions().Schema, map[string]interface{}{
+		"repository_url": ts.URL,
+		"chart":          "redis",
+	})
+	for i := 0; i < 2; i++ {
+		if diags := dataSourceHelmChartVersionsRead(context.Background(), d, cfg); diags.HasError() {
+			t.Fatalf("dataSourceHelmChartVersionsRead failed: %v", diags)
+		}
+	}
+	if downloads != 1 {
+		t.Errorf("expected the cached index to be reused within the TTL, got %d downloads", downloads)
+	}
+
+	// The stale index is downloaded again
+	indexPath := filepath.Join(cfg.CacheDir, "index", generateHash(ts.URL), "index.yaml")
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(indexPath, old, old); err != nil {
+		t.Fatalf("failed to change times of the cached index: %v", err)
+	}
+	if diags := dataSourceHelmChartVersionsRead(context.Background(), d, cfg); diags.HasError() {
+		t.Fatalf("dataSourceHelmChartVersionsRead failed: %v", diags)
+	}
+	if downloads != 2 {
+		t.Errorf("expected the stale index to be downloaded, got %d downloads", downloads)
+	}
+}
+
+// TestDataSourceHelmChartVersionsReadLegacyCache tests that the index cached by the earlier provider versions is kept
+func TestDataSourceHelmChartVersionsReadLegacyCache(t *testing.T) {
+	cfg := MockProviderConfig()
+	cfg.CacheDir = t.TempDir()
+	cfg.Offline = true
+
+	repositoryURL := "https://charts.example.com"
+	legacyPath := filepath.Join(cfg.CacheDir, "index", truncatedHash(repositoryURL, legacyCacheHashLength))
+	if err := os.MkdirAll(legacyPath, os.ModePerm); err != nil {
+		t.Fatalf("failed to create the legacy cache directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(legacyPath, "index.yaml"), []byte(testRepositoryIndex), 0600); err != nil {
+		t.Fatalf("failed to create the legacy cached index: %v", err)
+	}
+
+	d := schema.TestResourceDataRaw(t, dataSourceHelmChartVersions().Schema, map[string]interface{}{
+		"repository_url": repositoryURL,
+		"chart":          "redis",
+	})
+	if diags := dataSourceHelmChartVersionsRead(context.Background(), d, cfg); diags.HasError() {
+		t.Fatalf("dataSourceHelmChartVersionsRead failed: %v", diags)
+	}
+	if versions := d.Get("versions").([]interface{}); len(versions) == 0 {
+		t.Errorf("expected the versions from the legacy cached index")
+	}
+	if _, err := os.Stat(filepath.Join(cfg.CacheDir, "index", generateHash(repositoryURL), "index.yaml")); err != nil {
+		t.Errorf("legacy cached index isn't migrated: %v", err)
+	}
+}
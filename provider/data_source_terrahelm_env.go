@@ -0,0 +1,115 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// helmEnvAttributes maps the data source attributes to the 'helm env' variables
+var helmEnvAttributes = map[string]string{
+	"cache_home":        "HELM_CACHE_HOME",
+	"config_home":       "HELM_CONFIG_HOME",
+	"data_home":         "HELM_DATA_HOME",
+	"plugins_dir":       "HELM_PLUGINS",
+	"registry_config":   "HELM_REGISTRY_CONFIG",
+	"repository_cache":  "HELM_REPOSITORY_CACHE",
+	"repository_config": "HELM_REPOSITORY_CONFIG",
+}
+
+func dataSourceHelmEnv() *schema.Resource {
+	return &schema.Resource{
+		Description: "Effective Helm environment of the provider Helm commands",
+		ReadContext: dataSourceHelmEnvRead,
+		Schema: map[string]*schema.Schema{
+			"cache_home": {
+				Description: "The Helm cache home directory",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"config_home": {
+				Description: "The Helm config home directory",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"data_home": {
+				Description: "The Helm data home directory",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"plugins_dir": {
+				Description: "The Helm plugins directory",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"registry_config": {
+				Description: "The path to the Helm registry config file",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"repository_cache": {
+				Description: "The path to the Helm repositories cache directory",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"repository_config": {
+				Description: "The path to the Helm repositories config file",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"env": {
+				Description: "All the 'HELM_*' environment variables reported by 'helm env'",
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+		},
+	}
+}
+
+func dataSourceHelmEnvRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config := m.(*ProviderConfig)
+
+	// Helm reports the environment it's run with, so the provider paths and the 'helm_env' overrides are included
+	envCmd := config.HelmCmd("env")
+	var envCmdStderr bytes.Buffer
+	envCmd.Stderr = &envCmdStderr
+	output, err := config.output(envCmd)
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("failed to retrieve Helm environment: %s\nHelm output: %s", err, stripANSI(envCmdStderr.String())))
+	}
+
+	env := parseHelmEnv(output)
+	for attribute, name := range helmEnvAttributes {
+		d.Set(attribute, env[name])
+	}
+	if err := d.Set("env", env); err != nil {
+		return diag.FromErr(err)
+	}
+	d.SetId(config.CacheDir)
+
+	return nil
+}
+
+// parseHelmEnv parses the 'helm env' output of the 'NAME="value"' lines
+func parseHelmEnv(output []byte) map[string]string {
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok || !strings.HasPrefix(name, "HELM_") {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		env[name] = value
+	}
+	return env
+}
@@ -0,0 +1,59 @@
+package provider
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// TestDataSourceHelmEnvRead tests the dataSourceHelmEnvRead function
+func TestDataSourceHelmEnvRead(t *testing.T) {
+	cfg := MockProviderConfig()
+	cfg.HelmCmd = func(args ...string) *exec.Cmd {
+		if len(args) != 1 || args[0] != "env" {
+			t.Errorf("unexpected Helm command: %v", args)
+		}
+		return exec.Command("printf", "%s\n",
+			`HELM_BIN="/cache/helm/v3.14.2/helm"`,
+			`HELM_CACHE_HOME="/root/.cache/helm"`,
+			`HELM_CONFIG_HOME="/root/.config/helm"`,
+			`HELM_DATA_HOME="/root/.local/share/helm"`,
+			`HELM_PLUGINS="/root/.local/share/helm/plugins"`,
+			`HELM_REGISTRY_CONFIG="/cache/config/registry.json"`,
+			`HELM_REPOSITORY_CACHE="/cache/repository"`,
+			`HELM_REPOSITORY_CONFIG="/cache/config/repositories.yaml"`,
+		)
+	}
+
+	d := schema.TestResourceDataRaw(t, dataSourceHelmEnv().Schema, nil)
+	if diags := dataSourceHelmEnvRead(context.Background(), d, cfg); diags.HasError() {
+		t.Fatalf("dataSourceHelmEnvRead failed: %v", diags)
+	}
+
+	expected := map[string]string{
+		"cache_home":        "/root/.cache/helm",
+		"config_home":       "/root/.config/helm",
+		"data_home":         "/root/.local/share/helm",
+		"plugins_dir":       "/root/.local/share/helm/plugins",
+		"registry_config":   "/cache/config/registry.json",
+		"repository_cache":  "/cache/repository",
+		"repository_config": "/cache/config/repositories.yaml",
+	}
+	for key, value := range expected {
+		if v := d.Get(key); v != value {
+			t.Errorf("unexpected %s: %s", key, v)
+		}
+	}
+
+	env := d.Get("env").(map[string]interface{})
+	for _, name := range helmEnvAttributes {
+		if _, ok := env[name]; !ok {
+			t.Errorf("%s is missing in the env: %v", name, env)
+		}
+	}
+	if env["HELM_BIN"] != "/cache/helm/v3.14.2/helm" {
+		t.Errorf("unexpected HELM_BIN: %v", env["HELM_BIN"])
+	}
+}
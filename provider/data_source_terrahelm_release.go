@@ -2,8 +2,12 @@ package provider
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
@@ -11,14 +15,21 @@ import (
 func dataSourceHelmRelease() *schema.Resource {
 	return &schema.Resource{
 		Description: "Helm chart data",
-		ReadContext: dataSourceHelmReleaseRead,
+		ReadContext: withReleaseLock(withCommandTimeout(dataSourceHelmReleaseRead)),
 		Schema: map[string]*schema.Schema{
 			"name": {
 				Description: "Name of the Helm release",
 				Type:        schema.TypeString,
-				Required:    true,
+				Optional:    true,
+				Computed:    true,
 				ForceNew:    true,
 			},
+			"selector": {
+				Description:  "Label selector of the Helm release storage labels to find the release by, e.g. 'owner=helm,name=nginx', exactly one release must match",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ExactlyOneOf: []string{"name", "selector"},
+			},
 			"namespace": {
 				Description: "The Kubernetes namespace where the Helm chart will be installed",
 				Type:        schema.TypeString,
@@ -26,6 +37,11 @@ func dataSourceHelmRelease() *schema.Resource {
 				Default:     "default",
 				ForceNew:    true,
 			},
+			"revision": {
+				Description: "The revision of the Helm release to read, the latest one is used by default",
+				Type:        schema.TypeInt,
+				Optional:    true,
+			},
 			"release_revision": {
 				Description: "The revision of the installed Helm release",
 				Type:        schema.TypeString,
@@ -49,11 +65,42 @@ func dataSourceHelmRelease() *schema.Resource {
 					Type: schema.TypeString,
 				},
 			},
+			"release_values_json": {
+				Description: "The values passed to the Helm chart at installation time as JSON keeping the value types, use 'jsondecode' to access them",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
 			"release_status": {
 				Description: "The current status of the installed Helm release",
 				Type:        schema.TypeString,
 				Computed:    true,
 			},
+			"release_description": {
+				Description: "The description of the last operation of the installed Helm release, e.g. 'Upgrade complete'",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"release_namespace": {
+				Description: "The namespace of the installed Helm release",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"release_updated": {
+				Description: "The time of the last deployment of the installed Helm release in RFC 3339 format",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"release_app_version": {
+				Description: "The app version of the installed Helm chart",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"release_hooks": releaseHooksSchema(),
+			"release_notes": {
+				Description: "The rendered notes (NOTES.txt) of the installed Helm release",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
 		},
 	}
 }
@@ -62,7 +109,116 @@ func dataSourceHelmReleaseRead(ctx context.Context, d *schema.ResourceData, m in
 	name := d.Get("name").(string)
 	namespace := d.Get("namespace").(string)
 
-	d.SetId(fmt.Sprintf("%s/%s", namespace, name))
+	// the resource schema doesn't have the selector attribute
+	if selector, _ := d.Get("selector").(string); selector != "" {
+		var err error
+		if name, err = findReleaseBySelector(m.(*ProviderConfig), namespace, selector); err != nil {
+			return diag.FromErr(err)
+		}
+		d.Set("name", name)
+	}
+
+	d.SetId(m.(*ProviderConfig).releaseID(namespace, name))
+
+	if revision, _ := d.Get("revision").(int); revision > 0 {
+		return dataSourceHelmReleaseReadRevision(ctx, d, m, revision)
+	}
 
 	return resourceHelmReleaseRead(ctx, d, m)
 }
+
+// findReleaseBySelector returns the name of the only Helm release matching the label selector
+func findReleaseBySelector(config *ProviderConfig, namespace, selector string) (string, error) {
+	output, err := config.output(config.HelmCmd("list", "-n", namespace, "-l", selector, "-o", "json"))
+	if err != nil {
+		return "", fmt.Errorf("failed to list Helm releases by selector '%s': %s", selector, err)
+	}
+
+	var releases []struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(output, &releases); err != nil {
+		return "", fmt.Errorf("failed to unmarshal Helm releases: %s", err)
+	}
+
+	switch len(releases) {
+	case 0:
+		return "", fmt.Errorf("no Helm release matches the selector '%s' in the namespace '%s'", selector, namespace)
+	case 1:
+		return releases[0].Name, nil
+	}
+
+	var names []string
+	for _, r := range releases {
+		names = append(names, r.Name)
+	}
+	return "", fmt.Errorf("multiple Helm releases match the selector '%s' in the namespace '%s': %s", selector, namespace, strings.Join(names, ", "))
+}
+
+// dataSourceHelmReleaseReadRevision reads the given Helm release revision
+func dataSourceHelmReleaseReadRevision(ctx context.Context, d *schema.ResourceData, m interface{}, revision int) diag.Diagnostics {
+	name := d.Get("name").(string)
+	namespace := d.Get("namespace").(string)
+
+	config := m.(*ProviderConfig)
+
+	tflog.Debug(ctx, fmt.Sprintf("getting the Helm release revision: %d", revision))
+	status, err := getHelmStatus(config, name, namespace, revision)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return diag.Errorf("revision %d of the Helm release '%s' doesn't exist in the namespace '%s'", revision, name, namespace)
+		}
+		return diag.FromErr(err)
+	}
+
+	d.Set("release_chart_name", status.Chart.Metadata.Name)
+	d.Set("release_chart_version", status.Chart.Metadata.Version)
+	d.Set("release_revision", strconv.Itoa(status.Version))
+	d.Set("release_status", status.Info.Status)
+	d.Set("release_description", status.Info.Description)
+	d.Set("release_namespace", status.Namespace)
+	d.Set("release_updated", releaseTime(status.Info.LastDeployed))
+	d.Set("release_app_version", status.Chart.Metadata.AppVersion)
+
+	valuesCmd := config.HelmCmd("get", "values", "-n", namespace, name, "--revision", strconv.Itoa(revision), "-a", "-o", "json")
+	valuesOutput, err := config.output(valuesCmd)
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("failed to retrieve Helm release values: %s", err))
+	}
+
+	var rawValues map[string]interface{}
+	if err := json.Unmarshal(valuesOutput, &rawValues); err != nil {
+		return diag.FromErr(fmt.Errorf("failed to unmarshal Helm release values: %s", err))
+	}
+
+	flatValuesMap, err := jsonMapToStringMap(rawValues)
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("failed to convert Helm release values: %s", err))
+	}
+
+	if err := d.Set("release_values", flatValuesMap); err != nil {
+		return diag.FromErr(err)
+	}
+
+	valuesJSON, err := compactJSON(valuesOutput)
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("failed to convert Helm release values to JSON: %s", err))
+	}
+	d.Set("release_values_json", valuesJSON)
+
+	hooks, err := getHelmHooks(config, name, namespace, revision)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("release_hooks", hooks); err != nil {
+		return diag.FromErr(err)
+	}
+
+	notes, err := getHelmNotes(config, name, namespace, revision)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	d.Set("release_notes", notes)
+
+	return nil
+}
@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"os/exec"
 	"strings"
 	"testing"
 
@@ -27,3 +28,124 @@ func TestDataSourceHelmReleaseRead(t *testing.T) {
 		t.Errorf("unexpected values: %s, %v", values, []byte(values))
 	}
 }
+
+// TestDataSourceHelmReleaseReadSchema tests the dataSourceHelmReleaseRead function with the data source schema
+func TestDataSourceHelmReleaseReadSchema(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, dataSourceHelmRelease().Schema, nil)
+	d.Set("name", "test-helm-release")
+	d.Set("namespace", "test-namespace")
+
+	if diags := dataSourceHelmReleaseRead(context.Background(), d, config); diags.HasError() {
+		t.Fatalf("dataSourceHelmReleaseRead failed: %v", diags)
+	}
+	if status := d.Get("release_status"); status != "deployed" {
+		t.Errorf("unexpected release status: %s", status)
+	}
+}
+
+// TestDataSourceHelmReleaseReadRevision tests reading a specific Helm release revision
+func TestDataSourceHelmReleaseReadRevision(t *testing.T) {
+	var calls []*mockHelmCall
+	d := schema.TestResourceDataRaw(t, dataSourceHelmRelease().Schema, nil)
+	d.Set("name", "test-helm-release")
+	d.Set("namespace", "test-namespace")
+	d.Set("revision", 3)
+
+	if diags := dataSourceHelmReleaseRead(context.Background(), d, recordingProviderConfig(&calls)); diags.HasError() {
+		t.Fatalf("dataSourceHelmReleaseRead failed: %v", diags)
+	}
+
+	if args := findHelmCall(calls, "status"); !containsArgs(args, "--revision", "3") {
+		t.Errorf("unexpected status command: %v", args)
+	}
+	if args := findHelmCall(calls, "get"); !containsArgs(args, "--revision", "3") {
+		t.Errorf("unexpected get values command: %v", args)
+	}
+	if revision := d.Get("release_revision"); revision != "3" {
+		t.Errorf("unexpected release revision: %s", revision)
+	}
+	if chartVersion := d.Get("release_chart_version"); chartVersion != "13.2.32" {
+		t.Errorf("unexpected release chart version: %s", chartVersion)
+	}
+	if appVersion := d.Get("release_app_version"); appVersion != "1.23.4" {
+		t.Errorf("unexpected release app version: %s", appVersion)
+	}
+	if releaseNamespace := d.Get("release_namespace"); releaseNamespace != "test-namespace" {
+		t.Errorf("unexpected release namespace: %s", releaseNamespace)
+	}
+	if description := d.Get("release_description"); description != "Upgrade complete" {
+		t.Errorf("unexpected release description: %s", description)
+	}
+	if replicaCount := d.Get("release_values.replicaCount"); replicaCount != "1" {
+		t.Errorf("unexpected release values: %v", d.Get("release_values"))
+	}
+}
+
+// TestDataSourceHelmReleaseReadRevisionNotFound tests reading a missing Helm release revision
+func TestDataSourceHelmReleaseReadRevisionNotFound(t *testing.T) {
+	cfg := MockProviderConfig()
+	cfg.HelmCmd = func(args ...string) *exec.Cmd {
+		return exec.Command("sh", "-c", "echo 'Error: release: not found' >&2; exit 1")
+	}
+
+	d := schema.TestResourceDataRaw(t, dataSourceHelmRelease().Schema, nil)
+	d.Set("name", "test-helm-release")
+	d.Set("namespace", "test-namespace")
+	d.Set("revision", 42)
+
+	diags := dataSourceHelmReleaseRead(context.Background(), d, cfg)
+	if !diags.HasError() {
+		t.Fatalf("expected missing revision to fail")
+	}
+	if !strings.Contains(diags[0].Summary, "revision 42 of the Helm release 'test-helm-release' doesn't exist") {
+		t.Errorf("unexpected error: %s", diags[0].Summary)
+	}
+}
+
+// TestDataSourceHelmReleaseReadSelector tests finding the Helm release by the label selector
+func TestDataSourceHelmReleaseReadSelector(t *testing.T) {
+	tests := []struct {
+		name     string
+		releases string
+		errMsg   string
+	}{
+		{"single match", `[{"name":"test-helm-release"}]`, ""},
+		{"no match", `[]`, "no Helm release matches the selector"},
+		{"ambiguous match", `[{"name":"nginx-a"},{"name":"nginx-b"}]`, "multiple Helm releases match the selector 'app=nginx' in the namespace 'test-namespace': nginx-a, nginx-b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := MockProviderConfig()
+			helmCmd := cfg.HelmCmd
+			cfg.HelmCmd = func(args ...string) *exec.Cmd {
+				if args[0] == "list" && containsArgs(args, "-l", "app=nginx") {
+					return exec.Command("echo", tt.releases)
+				}
+				return helmCmd(args...)
+			}
+
+			d := schema.TestResourceDataRaw(t, dataSourceHelmRelease().Schema, nil)
+			d.Set("selector", "app=nginx")
+			d.Set("namespace", "test-namespace")
+
+			diags := dataSourceHelmReleaseRead(context.Background(), d, cfg)
+			if tt.errMsg != "" {
+				if !diags.HasError() || !strings.Contains(diags[0].Summary, tt.errMsg) {
+					t.Fatalf("expected error %q, got: %v", tt.errMsg, diags)
+				}
+				return
+			}
+
+			if diags.HasError() {
+				t.Fatalf("dataSourceHelmReleaseRead failed: %v", diags)
+			}
+			if name := d.Get("name"); name != "test-helm-release" {
+				t.Errorf("unexpected release name: %s", name)
+			}
+			if id := d.Id(); id != "test-namespace/test-helm-release" {
+				t.Errorf("unexpected data source ID: %s", id)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func dataSourceHelmTemplate() *schema.Resource {
+	return &schema.Resource{
+		Description: "Helm chart rendered locally with 'helm template'",
+		ReadContext: withCommandTimeout(dataSourceHelmTemplateRead),
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Description: "Name of the Helm release",
+				Type:        schema.TypeString,
+				Required:    true,
+			},
+			"namespace": {
+				Description: "The Kubernetes namespace used for rendering the Helm chart",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "default",
+			},
+			"chart_repository": {
+				Description: "Name of the added chart repository or local directory containing the Helm chart",
+				Type:        schema.TypeString,
+				Required:    true,
+			},
+			"chart_path": {
+				Description: "The relative path to the Helm chart",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"chart_version": {
+				Description: "The version of the Helm chart to render",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"values": {
+				Description: "A YAML string representing the values to be passed to the Helm chart",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"kube_version": {
+				Description: "Kubernetes version used for the '.Capabilities.KubeVersion'",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"api_versions": {
+				Description: "Kubernetes API versions used for the '.Capabilities.APIVersions'",
+				Type:        schema.TypeList,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+				Optional: true,
+			},
+			"include_crds": {
+				Description: "Include CRDs in the rendered manifest",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"render_subchart_notes": {
+				Description: "Render the subchart notes along with the parent chart notes",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"manifest": {
+				Description: "The rendered Helm chart manifest",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func dataSourceHelmTemplateRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	name := d.Get("name").(string)
+	namespace := d.Get("namespace").(string)
+	chartRepository := d.Get("chart_repository").(string)
+	chartPath := d.Get("chart_path").(string)
+	chartVersion := d.Get("chart_version").(string)
+	values := d.Get("values").(string)
+	kubeVersion := d.Get("kube_version").(string)
+	apiVersions := d.Get("api_versions").([]interface{})
+	includeCRDs := d.Get("include_crds").(bool)
+	renderSubchartNotes := d.Get("render_subchart_notes").(bool)
+
+	config := m.(*ProviderConfig)
+
+	helmCmd := config.HelmCmd("template", name, filepath.Join(chartRepository, chartPath), "--namespace", namespace)
+
+	if values != "" {
+		valuesPath := filepath.Join(config.CacheDir, "values", name)
+		if err := os.MkdirAll(valuesPath, os.ModePerm); err != nil {
+			return diag.FromErr(fmt.Errorf("failed to create the directory for values: %s", err))
+		}
+
+		valuesFilePath := filepath.Join(valuesPath, fmt.Sprintf("%s-%s-values.yaml", name, generateHash(values)))
+		if err := os.WriteFile(valuesFilePath, []byte(values), os.ModePerm); err != nil {
+			return diag.FromErr(fmt.Errorf("failed to create Helm values file: %s", err))
+		}
+
+		helmCmd.Args = append(helmCmd.Args, "-f", valuesFilePath)
+	}
+	if chartVersion != "" {
+		helmCmd.Args = append(helmCmd.Args, "--version", chartVersion)
+	}
+	if config.CABundleFile != "" {
+		helmCmd.Args = append(helmCmd.Args, "--ca-file", config.CABundleFile)
+	}
+	if kubeVersion != "" {
+		helmCmd.Args = append(helmCmd.Args, "--kube-version", kubeVersion)
+	}
+	for _, v := range apiVersions {
+		helmCmd.Args = append(helmCmd.Args, "--api-versions", v.(string))
+	}
+	if includeCRDs {
+		helmCmd.Args = append(helmCmd.Args, "--include-crds")
+	}
+	if renderSubchartNotes {
+		helmCmd.Args = append(helmCmd.Args, "--render-subchart-notes")
+	}
+
+	var helmCmdStdout, helmCmdStderr bytes.Buffer
+	helmCmd.Stdout = &helmCmdStdout
+	helmCmd.Stderr = &helmCmdStderr
+	helmCmdString := redactArgs(helmCmd.Args)
+	tflog.Debug(ctx, fmt.Sprintf("Rendering Helm chart: %s", helmCmdString))
+	if err := config.run(helmCmd); err != nil {
+		return diag.FromErr(fmt.Errorf("failed to render the Helm chart: %s\nHelm command: %s\nHelm output: %s", err, helmCmdString, stripANSI(helmCmdStderr.String())))
+	}
+
+	d.Set("manifest", strings.TrimSpace(helmCmdStdout.String()))
+	d.SetId(fmt.Sprintf("%s/%s", namespace, name))
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// TestDataSourceHelmTemplateRead tests the dataSourceHelmTemplateRead function
+func TestDataSourceHelmTemplateRead(t *testing.T) {
+	var calls []*mockHelmCall
+	d := schema.TestResourceDataRaw(t, dataSourceHelmTemplate().Schema, nil)
+	d.Set("name", "test-helm-release")
+	d.Set("namespace", "test-namespace")
+	d.Set("chart_repository", "bitnami")
+	d.Set("chart_path", "nginx")
+	d.Set("kube_version", "1.29.0")
+	d.Set("api_versions", []interface{}{"monitoring.coreos.com/v1", "networking.istio.io/v1beta1"})
+
+	if diags := dataSourceHelmTemplateRead(context.Background(), d, recordingProviderConfig(&calls)); diags.HasError() {
+		t.Fatalf("dataSourceHelmTemplateRead failed: %v", diags)
+	}
+
+	args := findHelmCall(calls, "template")
+	if !containsArgs(args, "template", "test-helm-release", "bitnami/nginx") {
+		t.Errorf("unexpected template command: %v", args)
+	}
+	if !containsArgs(args, "--kube-version", "1.29.0") {
+		t.Errorf("missing '--kube-version' argument: %v", args)
+	}
+	if !containsArgs(args, "--api-versions", "monitoring.coreos.com/v1", "--api-versions", "networking.istio.io/v1beta1") {
+		t.Errorf("missing '--api-versions' arguments: %v", args)
+	}
+	if id := d.Id(); id != "test-namespace/test-helm-release" {
+		t.Errorf("unexpected data source ID: %s", id)
+	}
+	if manifest := d.Get("manifest").(string); manifest == "" {
+		t.Errorf("unexpected empty manifest")
+	}
+}
+
+// TestDataSourceHelmTemplateReadRenderOptions tests the rendering options arguments
+func TestDataSourceHelmTemplateReadRenderOptions(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		var calls []*mockHelmCall
+		d := schema.TestResourceDataRaw(t, dataSourceHelmTemplate().Schema, nil)
+		d.Set("name", "test-helm-release")
+		d.Set("chart_repository", "bitnami")
+		d.Set("chart_path", "nginx")
+		d.Set("include_crds", enabled)
+		d.Set("render_subchart_notes", enabled)
+
+		if diags := dataSourceHelmTemplateRead(context.Background(), d, recordingProviderConfig(&calls)); diags.HasError() {
+			t.Fatalf("dataSourceHelmTemplateRead failed: %v", diags)
+		}
+
+		args := findHelmCall(calls, "template")
+		if containsArgs(args, "--include-crds") != enabled {
+			t.Errorf("unexpected '--include-crds' presence (enabled: %v): %v", enabled, args)
+		}
+		if containsArgs(args, "--render-subchart-notes") != enabled {
+			t.Errorf("unexpected '--render-subchart-notes' presence (enabled: %v): %v", enabled, args)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// helmVersionTemplate renders the 'helm version' build info as JSON, since the command has no JSON output
+const helmVersionTemplate = `{"version":"{{.Version}}","git_commit":"{{.GitCommit}}","git_tree_state":"{{.GitTreeState}}","go_version":"{{.GoVersion}}"}`
+
+// helmBuildInfo represents the 'helm version' build info
+type helmBuildInfo struct {
+	Version      string `json:"version"`
+	GitCommit    string `json:"git_commit"`
+	GitTreeState string `json:"git_tree_state"`
+	GoVersion    string `json:"go_version"`
+}
+
+func dataSourceHelmVersion() *schema.Resource {
+	return &schema.Resource{
+		Description: "Helm binary used by the provider",
+		ReadContext: dataSourceHelmVersionRead,
+		Schema: map[string]*schema.Schema{
+			"version": {
+				Description: "The version of the Helm binary",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"git_commit": {
+				Description: "The git commit the Helm binary is built from",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"go_version": {
+				Description: "The Go version the Helm binary is built with",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"helm_bin_path": {
+				Description: "The path to the Helm binary",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"cache_dir": {
+				Description: "The provider cache directory path",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func dataSourceHelmVersionRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config := m.(*ProviderConfig)
+
+	output, err := config.output(config.HelmCmd("version", "--template", helmVersionTemplate))
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("failed to retrieve Helm version: %s", err))
+	}
+
+	var buildInfo helmBuildInfo
+	if err := json.Unmarshal(output, &buildInfo); err != nil {
+		return diag.FromErr(fmt.Errorf("failed to unmarshal Helm version: %s", err))
+	}
+
+	d.Set("version", buildInfo.Version)
+	d.Set("git_commit", buildInfo.GitCommit)
+	d.Set("go_version", buildInfo.GoVersion)
+	d.Set("helm_bin_path", config.HelmBinPath)
+	d.Set("cache_dir", config.CacheDir)
+	d.SetId(buildInfo.Version)
+
+	return nil
+}
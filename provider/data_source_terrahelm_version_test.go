@@ -0,0 +1,42 @@
+package provider
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// TestDataSourceHelmVersionRead tests the dataSourceHelmVersionRead function
+func TestDataSourceHelmVersionRead(t *testing.T) {
+	cfg := MockProviderConfig()
+	cfg.HelmBinPath = "/cache/helm/v3.14.2/helm"
+	cfg.HelmCmd = func(args ...string) *exec.Cmd {
+		if len(args) < 3 || args[0] != "version" || args[2] != helmVersionTemplate {
+			t.Errorf("unexpected Helm command: %v", args)
+		}
+		return exec.Command("echo", `{"version":"v3.14.2","git_commit":"c309b6f0ff63856811846ce18f3bdc93d2b4d54b","git_tree_state":"clean","go_version":"go1.21.7"}`)
+	}
+
+	d := schema.TestResourceDataRaw(t, dataSourceHelmVersion().Schema, nil)
+	if diags := dataSourceHelmVersionRead(context.Background(), d, cfg); diags.HasError() {
+		t.Fatalf("dataSourceHelmVersionRead failed: %v", diags)
+	}
+
+	expected := map[string]string{
+		"version":       "v3.14.2",
+		"git_commit":    "c309b6f0ff63856811846ce18f3bdc93d2b4d54b",
+		"go_version":    "go1.21.7",
+		"helm_bin_path": "/cache/helm/v3.14.2/helm",
+		"cache_dir":     cfg.CacheDir,
+	}
+	for key, value := range expected {
+		if v := d.Get(key); v != value {
+			t.Errorf("unexpected %s: %s", key, v)
+		}
+	}
+	if id := d.Id(); id != "v3.14.2" {
+		t.Errorf("unexpected data source ID: %s", id)
+	}
+}
@@ -0,0 +1,397 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// kubeClient is a minimal Kubernetes API client built from the provider kube auth,
+// it is used for the few operations Helm CLI can't perform by itself
+type kubeClient struct {
+	host    string
+	token   string
+	headers http.Header
+	client  *http.Client
+}
+
+// kubeconfig represents the subset of the kubeconfig file used by kubeClient
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+			TLSServerName            string `yaml:"tls-server-name"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string      `yaml:"token"`
+			TokenFile             string      `yaml:"tokenFile"`
+			ClientCertificate     string      `yaml:"client-certificate"`
+			ClientCertificateData string      `yaml:"client-certificate-data"`
+			ClientKey             string      `yaml:"client-key"`
+			ClientKeyData         string      `yaml:"client-key-data"`
+			Exec                  interface{} `yaml:"exec"`
+			AuthProvider          interface{} `yaml:"auth-provider"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+}
+
+// kubeconfigPaths returns the kubeconfig paths merged as Helm does: the configured ones, the configured paths or KUBECONFIG,
+// or the default one
+func (auth KubeAuth) kubeconfigPaths() []string {
+	var paths []string
+	switch {
+	case auth.Kubeconfig != "":
+		paths = filepath.SplitList(auth.Kubeconfig)
+	case len(auth.KubeconfigPaths) > 0:
+		paths = auth.KubeconfigPaths
+	default:
+		paths = filepath.SplitList(os.Getenv("KUBECONFIG"))
+	}
+
+	var kubeconfigPaths []string
+	for _, path := range paths {
+		if path != "" {
+			kubeconfigPaths = append(kubeconfigPaths, path)
+		}
+	}
+	if len(kubeconfigPaths) == 0 {
+		if home, err := os.UserHomeDir(); err == nil {
+			kubeconfigPaths = append(kubeconfigPaths, filepath.Join(home, ".kube", "config"))
+		}
+	}
+	return kubeconfigPaths
+}
+
+// loadKubeconfig reads and merges the kubeconfig files, the missing files are skipped and nil is returned if none is read.
+// The earlier files take precedence as in kubectl: the first current context is used, and the entries are looked up
+// by the first name match, since the entries of the later files are appended
+func loadKubeconfig(paths []string) (*kubeconfig, error) {
+	var merged *kubeconfig
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var kc kubeconfig
+		if err := yaml.Unmarshal(content, &kc); err != nil {
+			return nil, fmt.Errorf("failed to parse kubeconfig '%s': %w", path, err)
+		}
+
+		// The relative paths are resolved against the kubeconfig directory as in kubectl
+		dir := filepath.Dir(path)
+		for i := range kc.Clusters {
+			kc.Clusters[i].Cluster.CertificateAuthority = kubeconfigFilePath(dir, kc.Clusters[i].Cluster.CertificateAuthority)
+		}
+		for i := range kc.Users {
+			user := &kc.Users[i].User
+			user.TokenFile = kubeconfigFilePath(dir, user.TokenFile)
+			user.ClientCertificate = kubeconfigFilePath(dir, user.ClientCertificate)
+			user.ClientKey = kubeconfigFilePath(dir, user.ClientKey)
+		}
+
+		if merged == nil {
+			merged = &kubeconfig{}
+		}
+		if merged.CurrentContext == "" {
+			merged.CurrentContext = kc.CurrentContext
+		}
+		merged.Clusters = append(merged.Clusters, kc.Clusters...)
+		merged.Contexts = append(merged.Contexts, kc.Contexts...)
+		merged.Users = append(merged.Users, kc.Users...)
+	}
+	return merged, nil
+}
+
+// kubeconfigFilePath resolves the file path of the kubeconfig entry against the kubeconfig directory, the empty path is kept
+func kubeconfigFilePath(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// contextName returns the name of the used kube context, the current kubeconfig context is used if it isn't configured
+func (auth KubeAuth) contextName() string {
+	if auth.KubeContext != "" {
+		return auth.KubeContext
+	}
+
+	kc, err := loadKubeconfig(auth.kubeconfigPaths())
+	if err != nil || kc == nil {
+		return ""
+	}
+	return kc.CurrentContext
+}
+
+// newKubeClient creates a Kubernetes API client, explicitly configured kube auth fields take precedence over the kubeconfig ones.
+// The requests use the provider proxy and CA bundle as the downloads do
+func newKubeClient(auth KubeAuth, proxy ProxyConfig, caBundleFile string) (*kubeClient, error) {
+	var (
+		host, token, caFile, tlsServerName string
+		caData, certData, keyData          []byte
+		insecure                           bool
+	)
+
+	kc, err := loadKubeconfig(auth.kubeconfigPaths())
+	if err != nil {
+		return nil, err
+	}
+	if kc == nil && auth.Kubeconfig != "" {
+		return nil, fmt.Errorf("failed to read kubeconfig '%s': the file is not found", auth.Kubeconfig)
+	}
+	if kc != nil {
+		contextName := kc.CurrentContext
+		if auth.KubeContext != "" {
+			contextName = auth.KubeContext
+		}
+
+		for _, c := range kc.Contexts {
+			if c.Name != contextName {
+				continue
+			}
+			for _, cl := range kc.Clusters {
+				if cl.Name == c.Context.Cluster {
+					host = cl.Cluster.Server
+					caFile = cl.Cluster.CertificateAuthority
+					insecure = cl.Cluster.InsecureSkipTLSVerify
+					tlsServerName = cl.Cluster.TLSServerName
+					if cl.Cluster.CertificateAuthorityData != "" {
+						if caData, err = base64.StdEncoding.DecodeString(cl.Cluster.CertificateAuthorityData); err != nil {
+							return nil, fmt.Errorf("failed to decode kubeconfig certificate authority data: %w", err)
+						}
+					}
+					break
+				}
+			}
+			for _, u := range kc.Users {
+				if u.Name != c.Context.User {
+					continue
+				}
+				token = u.User.Token
+				if token == "" && u.User.TokenFile != "" {
+					tokenContent, err := os.ReadFile(u.User.TokenFile)
+					if err != nil {
+						return nil, fmt.Errorf("failed to read kubeconfig token file: %w", err)
+					}
+					token = strings.TrimSpace(string(tokenContent))
+				}
+				if certData, err = readDataOrFile(u.User.ClientCertificateData, u.User.ClientCertificate); err != nil {
+					return nil, fmt.Errorf("failed to read kubeconfig client certificate: %w", err)
+				}
+				if keyData, err = readDataOrFile(u.User.ClientKeyData, u.User.ClientKey); err != nil {
+					return nil, fmt.Errorf("failed to read kubeconfig client key: %w", err)
+				}
+				// The credential plugins aren't supported, so the requests aren't sent unauthenticated
+				if (u.User.Exec != nil || u.User.AuthProvider != nil) && token == "" && len(certData) == 0 && auth.KubeToken == "" {
+					return nil, fmt.Errorf("kubeconfig user '%s' uses the 'exec' or 'auth-provider' credentials, which aren't supported by the provider Kubernetes client, set 'kube_token'", u.Name)
+				}
+				break
+			}
+			break
+		}
+	}
+
+	if auth.KubeAPIServer != "" {
+		host = auth.KubeAPIServer
+	}
+	// The explicit token replaces the kubeconfig user credentials, so the client certificate isn't sent along
+	if auth.KubeToken != "" {
+		token = auth.KubeToken
+		certData, keyData = nil, nil
+	}
+	// The explicit CA settings replace the kubeconfig cluster ones, the insecure mode takes precedence over the CA file
+	if auth.KubeCAFile != "" || auth.KubeInsecureSkipTLSVerify {
+		insecure = auth.KubeInsecureSkipTLSVerify
+		caFile, caData = auth.KubeCAFile, nil
+		if insecure {
+			caFile = ""
+		}
+	}
+	if auth.KubeTLSServerName != "" {
+		tlsServerName = auth.KubeTLSServerName
+	}
+
+	if host == "" {
+		return nil, fmt.Errorf("failed to find Kubernetes API server address, set 'kube_apiserver' or 'kubeconfig'")
+	}
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+
+	httpClient, err := newHTTPClient(proxy, caBundleFile)
+	if err != nil {
+		return nil, err
+	}
+	transport := httpClient.Transport.(*http.Transport)
+
+	// The CA bundle is trusted unless the cluster has its own certificate authority
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: insecure,
+		ServerName:         tlsServerName,
+	}
+	if transport.TLSClientConfig != nil {
+		tlsConfig.RootCAs = transport.TLSClientConfig.RootCAs
+	}
+	if caFile != "" {
+		if caData, err = os.ReadFile(caFile); err != nil {
+			return nil, fmt.Errorf("failed to read Kubernetes CA file: %w", err)
+		}
+	}
+	if len(caData) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caData) {
+			return nil, fmt.Errorf("failed to parse Kubernetes certificate authority")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if len(certData) > 0 && len(keyData) > 0 {
+		cert, err := tls.X509KeyPair(certData, keyData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Kubernetes client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	headers := http.Header{}
+	if auth.KubeAsUser != "" {
+		headers.Set("Impersonate-User", auth.KubeAsUser)
+	}
+	if auth.KubeAsGroup != "" {
+		headers.Add("Impersonate-Group", auth.KubeAsGroup)
+	}
+
+	transport.TLSClientConfig = tlsConfig
+	return &kubeClient{
+		host:    strings.TrimSuffix(host, "/"),
+		token:   token,
+		headers: headers,
+		client:  &http.Client{Transport: transport},
+	}, nil
+}
+
+// do sends a request to the Kubernetes API and decodes the JSON response into out, if provided
+func (c *kubeClient) do(ctx context.Context, method, path, contentType string, body, out interface{}) (int, error) {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode request body: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.host+path, reqBody)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create Kubernetes API request: %w", err)
+	}
+	for key, values := range c.headers {
+		req.Header[key] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to call Kubernetes API %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, fmt.Errorf("failed to read Kubernetes API response: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return resp.StatusCode, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("request to Kubernetes API %s %s failed: HTTP status %v: %s", method, path, resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode Kubernetes API response: %w", err)
+		}
+	}
+
+	return resp.StatusCode, nil
+}
+
+// resourcePath returns the Kubernetes API path of the object, the resource name is resolved using the API discovery
+func (c *kubeClient) resourcePath(ctx context.Context, apiVersion, kind, namespace, name string) (string, error) {
+	groupPath := "/apis/" + apiVersion
+	if !strings.Contains(apiVersion, "/") {
+		groupPath = "/api/" + apiVersion
+	}
+
+	var resources struct {
+		Resources []struct {
+			Name       string `json:"name"`
+			Kind       string `json:"kind"`
+			Namespaced bool   `json:"namespaced"`
+		} `json:"resources"`
+	}
+	status, err := c.do(ctx, http.MethodGet, groupPath, "", nil, &resources)
+	if err != nil {
+		return "", err
+	}
+	if status == http.StatusNotFound {
+		return "", fmt.Errorf("API version '%s' is not found", apiVersion)
+	}
+
+	for _, r := range resources.Resources {
+		// skip the subresources, e.g. 'deployments/status'
+		if r.Kind != kind || strings.Contains(r.Name, "/") {
+			continue
+		}
+		if r.Namespaced {
+			return fmt.Sprintf("%s/namespaces/%s/%s/%s", groupPath, url.PathEscape(namespace), r.Name, url.PathEscape(name)), nil
+		}
+		return fmt.Sprintf("%s/%s/%s", groupPath, r.Name, url.PathEscape(name)), nil
+	}
+
+	return "", fmt.Errorf("kind '%s' is not found in API version '%s'", kind, apiVersion)
+}
+
+// readDataOrFile returns the base64 decoded data if set, the file content otherwise
+func readDataOrFile(data, file string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	if file != "" {
+		return os.ReadFile(file)
+	}
+	return nil, nil
+}
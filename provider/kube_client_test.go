@@ -0,0 +1,279 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestNewKubeClient tests that the kube auth fields take precedence over the kubeconfig ones
+func TestNewKubeClient(t *testing.T) {
+	kubeconfigPath := filepath.Join(t.TempDir(), "config")
+	kubeconfigContent := `
+current-context: dev
+clusters:
+- name: dev
+  cluster:
+    server: https://dev.example.com
+- name: prod
+  cluster:
+    server: https://prod.example.com
+contexts:
+- name: dev
+  context:
+    cluster: dev
+    user: dev
+- name: prod
+  context:
+    cluster: prod
+    user: prod
+users:
+- name: dev
+  user:
+    token: dev-token
+- name: prod
+  user:
+    token: prod-token
+`
+	if err := os.WriteFile(kubeconfigPath, []byte(kubeconfigContent), 0600); err != nil {
+		t.Fatalf("failed to write kubeconfig: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		auth  KubeAuth
+		host  string
+		token string
+	}{
+		{"current context", KubeAuth{Kubeconfig: kubeconfigPath}, "https://dev.example.com", "dev-token"},
+		{"context override", KubeAuth{Kubeconfig: kubeconfigPath, KubeContext: "prod"}, "https://prod.example.com", "prod-token"},
+		{"token override", KubeAuth{Kubeconfig: kubeconfigPath, KubeToken: "token"}, "https://dev.example.com", "token"},
+		{"apiserver override", KubeAuth{Kubeconfig: kubeconfigPath, KubeAPIServer: "localhost:6443"}, "https://localhost:6443", "dev-token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := newKubeClient(tt.auth, ProxyConfig{}, "")
+			if err != nil {
+				t.Fatalf("newKubeClient failed: %v", err)
+			}
+			if client.host != tt.host {
+				t.Errorf("unexpected host: %s", client.host)
+			}
+			if client.token != tt.token {
+				t.Errorf("unexpected token: %s", client.token)
+			}
+		})
+	}
+
+	if _, err := newKubeClient(KubeAuth{Kubeconfig: filepath.Join(t.TempDir(), "missing")}, ProxyConfig{}, ""); err == nil {
+		t.Errorf("expected missing kubeconfig to fail")
+	}
+}
+
+// TestNewKubeClientMergedKubeconfig tests that the kubeconfig files are merged with the earlier files taking precedence
+func TestNewKubeClientMergedKubeconfig(t *testing.T) {
+	dir := t.TempDir()
+	contexts := filepath.Join(dir, "contexts")
+	contextsContent := `
+current-context: dev
+contexts:
+- name: dev
+  context:
+    cluster: dev
+    user: dev
+`
+	clusters := filepath.Join(dir, "clusters")
+	clustersContent := `
+current-context: prod
+clusters:
+- name: dev
+  cluster:
+    server: https://dev.example.com
+users:
+- name: dev
+  user:
+    token: dev-token
+`
+	shadowed := filepath.Join(dir, "shadowed")
+	shadowedContent := `
+clusters:
+- name: dev
+  cluster:
+    server: https://shadowed.example.com
+`
+	for path, content := range map[string]string{contexts: contextsContent, clusters: clustersContent, shadowed: shadowedContent} {
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("failed to write kubeconfig: %v", err)
+		}
+	}
+
+	for _, auth := range []KubeAuth{
+		{KubeconfigPaths: []string{contexts, filepath.Join(dir, "missing"), clusters, shadowed}},
+		{Kubeconfig: strings.Join([]string{contexts, clusters, shadowed}, string(os.PathListSeparator))},
+	} {
+		client, err := newKubeClient(auth, ProxyConfig{}, "")
+		if err != nil {
+			t.Fatalf("newKubeClient failed: %v", err)
+		}
+		if client.host != "https://dev.example.com" || client.token != "dev-token" {
+			t.Errorf("unexpected host %s and token %s of the merged kubeconfig %v", client.host, client.token, auth)
+		}
+		if kubeContext := auth.contextName(); kubeContext != "dev" {
+			t.Errorf("unexpected kube context: %s", kubeContext)
+		}
+	}
+}
+
+// TestNewKubeClientKubeconfigUsers tests that the relative kubeconfig paths are resolved against the kubeconfig directory,
+// the unsupported credential plugins fail the client unless the token is set, and the provider proxy is used
+func TestNewKubeClientKubeconfigUsers(t *testing.T) {
+	dir := t.TempDir()
+	kubeconfigPath := filepath.Join(dir, "config")
+	kubeconfigContent := `
+current-context: file
+clusters:
+- name: dev
+  cluster:
+    server: https://dev.example.com
+contexts:
+- name: file
+  context:
+    cluster: dev
+    user: file
+- name: exec
+  context:
+    cluster: dev
+    user: exec
+users:
+- name: file
+  user:
+    tokenFile: token
+- name: exec
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: aws
+`
+	if err := os.WriteFile(kubeconfigPath, []byte(kubeconfigContent), 0600); err != nil {
+		t.Fatalf("failed to write kubeconfig: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "token"), []byte("file-token\n"), 0600); err != nil {
+		t.Fatalf("failed to write token file: %v", err)
+	}
+
+	client, err := newKubeClient(KubeAuth{Kubeconfig: kubeconfigPath}, ProxyConfig{HTTPSProxy: "http://proxy.example.com:3128"}, "")
+	if err != nil {
+		t.Fatalf("newKubeClient failed: %v", err)
+	}
+	if client.token != "file-token" {
+		t.Errorf("unexpected token: %s", client.token)
+	}
+	req, _ := http.NewRequest(http.MethodGet, client.host, nil)
+	if proxyURL, err := client.client.Transport.(*http.Transport).Proxy(req); err != nil || proxyURL == nil || proxyURL.Host != "proxy.example.com:3128" {
+		t.Errorf("unexpected proxy: %v, %v", proxyURL, err)
+	}
+
+	if _, err := newKubeClient(KubeAuth{Kubeconfig: kubeconfigPath, KubeContext: "exec"}, ProxyConfig{}, ""); err == nil || !strings.Contains(err.Error(), "'exec' or 'auth-provider'") {
+		t.Errorf("expected the exec credentials error, got: %v", err)
+	}
+	if client, err := newKubeClient(KubeAuth{Kubeconfig: kubeconfigPath, KubeContext: "exec", KubeToken: "token"}, ProxyConfig{}, ""); err != nil || client.token != "token" {
+		t.Errorf("expected the token to replace the exec credentials, got: %v", err)
+	}
+}
+
+// TestNewKubeClientCredentialsOverride tests that the explicit token and CA settings replace the kubeconfig ones,
+// the invalid kubeconfig certificates fail the client unless they're replaced
+func TestNewKubeClientCredentialsOverride(t *testing.T) {
+	kubeconfigPath := filepath.Join(t.TempDir(), "config")
+	kubeconfigContent := `
+current-context: dev
+clusters:
+- name: dev
+  cluster:
+    server: https://dev.example.com
+    certificate-authority-data: Zm9v
+contexts:
+- name: dev
+  context:
+    cluster: dev
+    user: dev
+users:
+- name: dev
+  user:
+    token: dev-token
+    client-certificate-data: Zm9v
+    client-key-data: Zm9v
+`
+	if err := os.WriteFile(kubeconfigPath, []byte(kubeconfigContent), 0600); err != nil {
+		t.Fatalf("failed to write kubeconfig: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		auth     KubeAuth
+		insecure bool
+		fails    bool
+	}{
+		{"kubeconfig credentials", KubeAuth{Kubeconfig: kubeconfigPath}, false, true},
+		{"token without CA override", KubeAuth{Kubeconfig: kubeconfigPath, KubeToken: "token"}, false, true},
+		{"token and insecure override", KubeAuth{Kubeconfig: kubeconfigPath, KubeToken: "token", KubeInsecureSkipTLSVerify: true}, true, false},
+		{"insecure over CA file", KubeAuth{Kubeconfig: kubeconfigPath, KubeToken: "token", KubeInsecureSkipTLSVerify: true, KubeCAFile: filepath.Join(t.TempDir(), "missing.pem")}, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := newKubeClient(tt.auth, ProxyConfig{}, "")
+			if tt.fails {
+				if err == nil {
+					t.Fatalf("expected the invalid kubeconfig certificates to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newKubeClient failed: %v", err)
+			}
+			if client.token != "token" {
+				t.Errorf("unexpected token: %s", client.token)
+			}
+			tlsConfig := client.client.Transport.(*http.Transport).TLSClientConfig
+			if tlsConfig.InsecureSkipVerify != tt.insecure || len(tlsConfig.Certificates) > 0 || tlsConfig.RootCAs != nil {
+				t.Errorf("unexpected TLS config: insecure %v, %d certificates, root CAs %v", tlsConfig.InsecureSkipVerify, len(tlsConfig.Certificates), tlsConfig.RootCAs)
+			}
+		})
+	}
+}
+
+// TestKubeClientDo tests the kubeClient request headers
+func TestKubeClientDo(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "Bearer token" {
+			t.Errorf("unexpected authorization header: %s", auth)
+		}
+		if user := r.Header.Get("Impersonate-User"); user != "admin" {
+			t.Errorf("unexpected impersonation header: %s", user)
+		}
+		w.Write([]byte(`{"kind":"Namespace"}`))
+	}))
+	defer ts.Close()
+
+	client, err := newKubeClient(KubeAuth{KubeAPIServer: ts.URL, KubeToken: "token", KubeAsUser: "admin"}, ProxyConfig{}, "")
+	if err != nil {
+		t.Fatalf("newKubeClient failed: %v", err)
+	}
+
+	var out struct {
+		Kind string `json:"kind"`
+	}
+	status, err := client.do(context.Background(), http.MethodGet, "/api/v1/namespaces/default", "", nil, &out)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if status != http.StatusOK || out.Kind != "Namespace" {
+		t.Errorf("unexpected response: %d %v", status, out)
+	}
+}
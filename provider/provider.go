@@ -1,30 +1,108 @@
 package provider
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/hashicorp/go-version"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"golang.org/x/net/http/httpproxy"
+	xproxy "golang.org/x/net/proxy"
 )
 
 const GET_HELM_URL = "https://raw.githubusercontent.com/helm/helm/master/scripts/get-helm-3"
 
 type ProviderConfig struct {
-	HelmBinPath string
-	GitBinPath  string
-	HelmVersion string
-	CacheDir    string
-	KubeAuth    KubeAuth
-	HelmCmd     func(args ...string) *exec.Cmd
+	HelmBinPath         string
+	GitBinPath          string
+	HelmVersion         string
+	CacheDir            string
+	KubeAuth            KubeAuth
+	Proxy               ProxyConfig
+	HelmPaths           HelmPaths
+	HelmEnv             map[string]string
+	CABundleFile        string
+	LogFormat           string
+	Offline             bool
+	PlanDiff            bool
+	IndexCacheTTL       time.Duration
+	CommandTimeout      time.Duration
+	IDIncludesContext   bool
+	HelmQuiet           bool
+	RequireGitReference bool
+	ReleaseNamePrefix   string
+	ReleaseNameSuffix   string
+	Repository          RepositoryAuth
+	HTTPClient          *http.Client
+	HelmCmd             func(args ...string) *exec.Cmd
+
+	helmVersionOnce sync.Once
+	helmSemVer      *version.Version
+	helmSemVerErr   error
+
+	releaseLocksMu sync.Mutex
+	releaseLocks   map[string]*sync.Mutex
+}
+
+// helmArgs returns the Helm flags of the kube auth. The flags override the matching fields of the kubeconfig context:
+// 'kube_apiserver' the server, 'kube_token' the user token, 'kube_ca_file' and 'kube_insecure_skip_tls_verify' the cluster CA.
+// The insecure mode takes precedence over the CA file, since Helm refuses to use both of them
+func (auth KubeAuth) helmArgs() []string {
+	var args []string
+	if auth.KubeAPIServer != "" {
+		args = append(args, "--kube-apiserver", auth.KubeAPIServer)
+	}
+	if auth.KubeAsUser != "" {
+		args = append(args, "--kube-as-user", auth.KubeAsUser)
+	}
+	if auth.KubeAsGroup != "" {
+		args = append(args, "--kube-as-group", auth.KubeAsGroup)
+	}
+	if auth.KubeCAFile != "" && !auth.KubeInsecureSkipTLSVerify {
+		args = append(args, "--kube-ca-file", auth.KubeCAFile)
+	}
+	if auth.KubeContext != "" {
+		args = append(args, "--kube-context", auth.KubeContext)
+	}
+	if auth.KubeInsecureSkipTLSVerify {
+		args = append(args, "--kube-insecure-skip-tls-verify")
+	}
+	if auth.KubeTLSServerName != "" {
+		args = append(args, "--kube-tls-server-name", auth.KubeTLSServerName)
+	}
+	if auth.KubeToken != "" {
+		args = append(args, "--kube-token", auth.KubeToken)
+	}
+	if auth.Kubeconfig != "" {
+		args = append(args, "--kubeconfig", auth.Kubeconfig)
+	}
+	return args
+}
+
+// RepositoryAuth is the default chart repository authentication of the releases
+type RepositoryAuth struct {
+	Username string
+	Password string
+	CAFile   string
 }
 
 type KubeAuth struct {
@@ -37,6 +115,117 @@ type KubeAuth struct {
 	KubeTLSServerName         string
 	KubeToken                 string
 	Kubeconfig                string
+	KubeconfigPaths           []string
+}
+
+type ProxyConfig struct {
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    string
+	AllProxy   string
+}
+
+// env returns the proxy environment variables for the external commands.
+// Helm reads only the HTTP and HTTPS proxy variables, so the SOCKS proxy is passed by them if they aren't set
+func (p ProxyConfig) env() []string {
+	httpProxy, httpsProxy := p.HTTPProxy, p.HTTPSProxy
+	if httpProxy == "" {
+		httpProxy = p.AllProxy
+	}
+	if httpsProxy == "" {
+		httpsProxy = p.AllProxy
+	}
+
+	var env []string
+	for name, value := range map[string]string{"HTTP_PROXY": httpProxy, "HTTPS_PROXY": httpsProxy, "NO_PROXY": p.NoProxy, "ALL_PROXY": p.AllProxy} {
+		if value != "" {
+			env = append(env, name+"="+value, strings.ToLower(name)+"="+value)
+		}
+	}
+	sort.Strings(env)
+	return env
+}
+
+type HelmPaths struct {
+	RepositoryConfig string
+	RepositoryCache  string
+	RegistryConfig   string
+}
+
+// env returns the Helm repository and registry config environment variables
+func (p HelmPaths) env() []string {
+	return []string{
+		"HELM_REGISTRY_CONFIG=" + p.RegistryConfig,
+		"HELM_REPOSITORY_CACHE=" + p.RepositoryCache,
+		"HELM_REPOSITORY_CONFIG=" + p.RepositoryConfig,
+	}
+}
+
+// helmHomePaths returns the Helm paths within the Helm home directory, the same layout as the Helm defaults is used
+func helmHomePaths(home string) HelmPaths {
+	return HelmPaths{
+		RepositoryConfig: filepath.Join(home, "config", "repositories.yaml"),
+		RepositoryCache:  filepath.Join(home, "cache", "repository"),
+		RegistryConfig:   filepath.Join(home, "config", "registry", "config.json"),
+	}
+}
+
+// withHelmHome returns the config copy which Helm commands keep the config, cache and data in the Helm home directory.
+// The release locks and the Helm version aren't shared with the copy, so it should be used within the locked operation only
+func (c *ProviderConfig) withHelmHome(home string) *ProviderConfig {
+	helmPaths := helmHomePaths(home)
+	env := append([]string{
+		"HELM_CACHE_HOME=" + filepath.Join(home, "cache"),
+		"HELM_CONFIG_HOME=" + filepath.Join(home, "config"),
+		"HELM_DATA_HOME=" + filepath.Join(home, "data"),
+	}, helmPaths.env()...)
+
+	helmCmd := c.HelmCmd
+	return &ProviderConfig{
+		HelmBinPath:         c.HelmBinPath,
+		GitBinPath:          c.GitBinPath,
+		HelmVersion:         c.HelmVersion,
+		CacheDir:            c.CacheDir,
+		KubeAuth:            c.KubeAuth,
+		Proxy:               c.Proxy,
+		HelmPaths:           helmPaths,
+		HelmEnv:             c.HelmEnv,
+		CABundleFile:        c.CABundleFile,
+		LogFormat:           c.LogFormat,
+		Offline:             c.Offline,
+		PlanDiff:            c.PlanDiff,
+		IDIncludesContext:   c.IDIncludesContext,
+		HelmQuiet:           c.HelmQuiet,
+		RequireGitReference: c.RequireGitReference,
+		ReleaseNamePrefix:   c.ReleaseNamePrefix,
+		ReleaseNameSuffix:   c.ReleaseNameSuffix,
+		Repository:          c.Repository,
+		IndexCacheTTL:       c.IndexCacheTTL,
+		CommandTimeout:      c.CommandTimeout,
+		HTTPClient:          c.HTTPClient,
+		HelmCmd: func(args ...string) *exec.Cmd {
+			cmd := helmCmd(args...)
+			if cmd.Env == nil {
+				cmd.Env = os.Environ()
+			}
+			// The later duplicates take precedence, so the provider Helm paths are overridden
+			cmd.Env = append(cmd.Env, env...)
+			return cmd
+		},
+	}
+}
+
+// quietEnv disables the colors and the debug output of Helm and its plugins
+var quietEnv = []string{"HELM_DEBUG=false", "NO_COLOR=1", "TERM=dumb"}
+
+// mapToEnv converts the map into the sorted list of environment variables
+func mapToEnv(vars map[string]string) []string {
+	var env []string
+	for name, value := range vars {
+		env = append(env, name+"="+value)
+	}
+	sort.Strings(env)
+	return env
 }
 
 func Provider() *schema.Provider {
@@ -54,6 +243,12 @@ func Provider() *schema.Provider {
 				DefaultFunc: schema.EnvDefaultFunc("HELM_BIN_PATH", ""),
 				Description: "If provided it will be used instead for installing Helm binary",
 			},
+			"min_helm_version": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("TH_MIN_HELM_VERSION", ""),
+				Description: "Minimum required Helm binary version or version constraint, e.g. '3.14.0' or '>= 3.14, < 4'",
+			},
 			"git_bin_path": {
 				Type:        schema.TypeString,
 				Optional:    true,
@@ -66,6 +261,216 @@ func Provider() *schema.Provider {
 				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"TF_DATA_DIR", "TH_CACHE"}, filepath.Join(".terraform", "terrahelm_cache")),
 				Description: "Provider cache directory path",
 			},
+			"cache_hash_length": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     16,
+				Description: "Length of the hashes naming the cached repositories, charts and values files, from 8 to 32. The longer hashes are less likely to collide for the different inputs, changing the length invalidates the earlier cache",
+				ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+					if length := val.(int); length < 8 || length > 32 {
+						errs = append(errs, fmt.Errorf("%q: must be from 8 to 32, got: %d", key, length))
+					}
+					return
+				},
+			},
+			"cache_max_age": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("TH_CACHE_MAX_AGE", ""),
+				Description: "Maximum age of the cached Helm binaries, repositories and values files, e.g. '168h'. Older ones are removed on provider start, disabled by default",
+				ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+					if val.(string) == "" {
+						return
+					}
+					if maxAge, err := time.ParseDuration(val.(string)); err != nil || maxAge <= 0 {
+						errs = append(errs, fmt.Errorf("%q: must be a positive duration, e.g. '168h', got: %s", key, val))
+					}
+					return
+				},
+			},
+			"command_timeout": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("TH_COMMAND_TIMEOUT", ""),
+				Description: "Maximum run time of each Helm, Git and post-install command, e.g. '30m'. The hung command is killed once it's exceeded, unlike the release 'timeout' which Helm applies to the in-cluster waits. Disabled by default",
+				ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+					if val.(string) == "" {
+						return
+					}
+					if timeout, err := time.ParseDuration(val.(string)); err != nil || timeout <= 0 {
+						errs = append(errs, fmt.Errorf("%q: must be a positive duration, e.g. '30m', got: %s", key, val))
+					}
+					return
+				},
+			},
+			"index_cache_ttl": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("TH_INDEX_CACHE_TTL", ""),
+				Description: "Freshness window of the cached chart repository indexes, e.g. '1h'. The fresh index is reused and the stale one is refreshed with 'helm repo update', the index is refreshed on each use by default",
+				ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+					if val.(string) == "" {
+						return
+					}
+					if ttl, err := time.ParseDuration(val.(string)); err != nil || ttl <= 0 {
+						errs = append(errs, fmt.Errorf("%q: must be a positive duration, e.g. '1h', got: %s", key, val))
+					}
+					return
+				},
+			},
+			"http_proxy": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"HTTP_PROXY", "http_proxy"}, ""),
+				Description: "Proxy URL for HTTP requests of the downloads, Helm and Git commands",
+			},
+			"https_proxy": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"HTTPS_PROXY", "https_proxy"}, ""),
+				Description: "Proxy URL for HTTPS requests of the downloads, Helm and Git commands",
+			},
+			"no_proxy": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"NO_PROXY", "no_proxy"}, ""),
+				Description: "Comma-separated list of hosts which should bypass the proxy",
+			},
+			"all_proxy": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"ALL_PROXY", "all_proxy"}, ""),
+				Description: "SOCKS5 proxy URL ('socks5://' or 'socks5h://') of the downloads, Helm and Git commands, e.g. when only the SOCKS proxy is exposed. The connections except the 'no_proxy' hosts are dialed through it, the 'http_proxy' and 'https_proxy' ones too",
+				ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+					if err := validateSOCKSProxy(val.(string)); err != nil {
+						errs = append(errs, fmt.Errorf("%q: %s", key, err))
+					}
+					return
+				},
+			},
+			"ca_bundle_file": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				DefaultFunc:   schema.EnvDefaultFunc("TH_CA_BUNDLE_FILE", ""),
+				ConflictsWith: []string{"ca_bundle"},
+				Description:   "Path to the PEM encoded CA bundle trusted for the downloads and the chart repositories in addition to the system ones",
+			},
+			"ca_bundle": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"ca_bundle_file"},
+				Description:   "PEM encoded CA bundle trusted for the downloads and the chart repositories in addition to the system ones",
+			},
+			"repository_username": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("TH_REPOSITORY_USERNAME", ""),
+				Description: "Default username for the chart repository authentication of the releases, the release 'repository_username' and 'repository_password' take precedence",
+			},
+			"repository_password": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				DefaultFunc: schema.EnvDefaultFunc("TH_REPOSITORY_PASSWORD", ""),
+				Description: "Default password for the chart repository authentication of the releases",
+			},
+			"repository_ca_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("TH_REPOSITORY_CA_FILE", ""),
+				Description: "Default path to the CA file verifying the chart repository certificate of the releases, the release 'repository_ca_file' takes precedence. It's used instead of 'ca_bundle_file' for the chart repository",
+			},
+			"helm_repository_config": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Path to the Helm repositories config file, defaults to the file in the cache_dir",
+			},
+			"helm_repository_cache": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Description:   "Path to the Helm repositories cache directory, defaults to the directory in the cache_dir",
+				ConflictsWith: []string{"repository_cache_dir"},
+			},
+			"repository_cache_dir": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				DefaultFunc:   schema.EnvDefaultFunc("TH_REPOSITORY_CACHE_DIR", ""),
+				Description:   "Long-lived directory of the Helm repository indexes and the pulled charts outside of the cache_dir, e.g. persisted between the CI runs with the ephemeral TF_DATA_DIR. It's created if missing, sets HELM_REPOSITORY_CACHE and isn't cleaned by 'cache_max_age'",
+				ConflictsWith: []string{"helm_repository_cache"},
+			},
+			"helm_registry_config": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Path to the Helm registry config file, defaults to the file in the cache_dir",
+			},
+			"helm_env": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Extra environment variables for the Helm commands, e.g. HELM_CACHE_HOME or the plugin ones. They take precedence over the Helm repository and registry paths, but not over the provider proxy settings",
+			},
+			"log_format": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("TH_LOG_FORMAT", "text"),
+				Description: "Log format of the Helm operations: 'text' or 'json'. The 'json' one emits the structured event with the Helm command, duration, exit code and release after each operation",
+				ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+					if v := val.(string); v != "text" && v != "json" {
+						errs = append(errs, fmt.Errorf("%q: must be 'text' or 'json', got: %s", key, v))
+					}
+					return
+				},
+			},
+			"offline": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("TH_OFFLINE", false),
+				Description: "Air-gapped mode: Helm binary isn't installed and the chart repository indexes aren't downloaded. Requires 'helm_bin_path' or Helm binary of 'helm_version' in the cache",
+			},
+			"disable_auto_install": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("TH_DISABLE_AUTO_INSTALL", false),
+				Description: "Never download Helm binary at runtime, 'helm_bin_path' is required then",
+			},
+			"plan_diff": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("TH_PLAN_DIFF", false),
+				Description: "Render the chart on plan and summarize the Kubernetes object changes against the live release in the 'planned_changes' attribute, requires the cluster access on plan",
+			},
+			"id_includes_context": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("TH_ID_INCLUDES_CONTEXT", false),
+				Description: "Include the kube context into the release IDs: '<context>/<namespace>/<name>' instead of '<namespace>/<name>', so the same release of the different clusters doesn't collide in the tooling. The ID of the existing releases is kept",
+			},
+			"require_git_reference": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("TH_REQUIRE_GIT_REFERENCE", false),
+				Description: "Require 'git_reference' of the releases using 'git_repository', so the deploys are pinned to the tag or the commit instead of the default branch",
+			},
+			"release_name_prefix": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("TH_RELEASE_NAME_PREFIX", ""),
+				Description:  "Prefix of the Helm release names of all the releases, e.g. the tenant name. The resource 'name' without it still forces the replacement, the releases installed with another prefix aren't found",
+				ValidateFunc: validateReleaseNameAffix,
+			},
+			"release_name_suffix": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("TH_RELEASE_NAME_SUFFIX", ""),
+				Description:  "Suffix of the Helm release names of all the releases, e.g. the environment name. The resource 'name' without it still forces the replacement, the releases installed with another suffix aren't found",
+				ValidateFunc: validateReleaseNameAffix,
+			},
+			"helm_quiet": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("TH_HELM_QUIET", false),
+				Description: "Reduce Helm output to the warnings and errors: the colors and the debug output are disabled and the output of the successful operations isn't logged",
+			},
 			"kube_apiserver": {
 				Type:        schema.TypeString,
 				Optional:    true,
@@ -121,12 +526,23 @@ func Provider() *schema.Provider {
 				DefaultFunc: schema.EnvDefaultFunc("KUBECONFIG", ""),
 				Description: "Path to the kubeconfig file",
 			},
+			"kubeconfig_paths": {
+				Type:          schema.TypeList,
+				Optional:      true,
+				Elem:          &schema.Schema{Type: schema.TypeString},
+				ConflictsWith: []string{"kubeconfig"},
+				Description:   "Paths to the kubeconfig files merged by Helm as the KUBECONFIG list, the earlier files take precedence",
+			},
 		},
 
 		ConfigureContextFunc: configureProvider,
 
 		DataSourcesMap: map[string]*schema.Resource{
-			"terrahelm_release": dataSourceHelmRelease(),
+			"terrahelm_release":        dataSourceHelmRelease(),
+			"terrahelm_template":       dataSourceHelmTemplate(),
+			"terrahelm_version":        dataSourceHelmVersion(),
+			"terrahelm_chart_versions": dataSourceHelmChartVersions(),
+			"terrahelm_env":            dataSourceHelmEnv(),
 		},
 
 		ResourcesMap: map[string]*schema.Resource{
@@ -138,21 +554,167 @@ func Provider() *schema.Provider {
 func configureProvider(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
 	helmVersion := d.Get("helm_version").(string)
 	helmBinPath := d.Get("helm_bin_path").(string)
+	minHelmVersion := d.Get("min_helm_version").(string)
 	gitGitBinPath := d.Get("git_bin_path").(string)
-	cacheDir := d.Get("cache_dir").(string)
+	// The paths are absolute, since Helm runs in the release working directory
+	cacheDir := absPath(d.Get("cache_dir").(string))
+	cacheMaxAge := d.Get("cache_max_age").(string)
+	// The hash length is set before any cached file is named
+	cacheHashLength = d.Get("cache_hash_length").(int)
+	indexCacheTTL := d.Get("index_cache_ttl").(string)
+	commandTimeout := d.Get("command_timeout").(string)
+	logFormat := d.Get("log_format").(string)
+	offline := d.Get("offline").(bool)
+	disableAutoInstall := d.Get("disable_auto_install").(bool)
+	planDiff := d.Get("plan_diff").(bool)
+	idIncludesContext := d.Get("id_includes_context").(bool)
+	helmQuiet := d.Get("helm_quiet").(bool)
+	requireGitReference := d.Get("require_git_reference").(bool)
+
+	helmPaths := HelmPaths{
+		RepositoryConfig: d.Get("helm_repository_config").(string),
+		RepositoryCache:  d.Get("helm_repository_cache").(string),
+		RegistryConfig:   d.Get("helm_registry_config").(string),
+	}
+	// Keep Helm config in the cache, so the user's Helm config isn't mutated
+	if helmPaths.RepositoryConfig == "" {
+		helmPaths.RepositoryConfig = filepath.Join(cacheDir, "config", "repositories.yaml")
+	}
+	// The long-lived repository cache from the environment doesn't override the configured one
+	repositoryCacheDir := d.Get("repository_cache_dir").(string)
+	if helmPaths.RepositoryCache == "" {
+		helmPaths.RepositoryCache = repositoryCacheDir
+	}
+	if helmPaths.RepositoryCache == "" {
+		helmPaths.RepositoryCache = filepath.Join(cacheDir, "repository")
+	}
+	if helmPaths.RegistryConfig == "" {
+		helmPaths.RegistryConfig = filepath.Join(cacheDir, "config", "registry.json")
+	}
+	helmPaths.RepositoryConfig = absPath(helmPaths.RepositoryConfig)
+	helmPaths.RepositoryCache = absPath(helmPaths.RepositoryCache)
+	helmPaths.RegistryConfig = absPath(helmPaths.RegistryConfig)
+
+	caBundleFile := absPath(d.Get("ca_bundle_file").(string))
+	caBundle := d.Get("ca_bundle").(string)
+
+	repositoryAuth := RepositoryAuth{
+		Username: d.Get("repository_username").(string),
+		Password: d.Get("repository_password").(string),
+		CAFile:   d.Get("repository_ca_file").(string),
+	}
+	if repositoryAuth.CAFile != "" {
+		if _, err := os.Stat(repositoryAuth.CAFile); err != nil {
+			return nil, diag.Errorf("'repository_ca_file' '%s' is not found", repositoryAuth.CAFile)
+		}
+	}
+
+	proxy := ProxyConfig{
+		HTTPProxy:  d.Get("http_proxy").(string),
+		HTTPSProxy: d.Get("https_proxy").(string),
+		NoProxy:    d.Get("no_proxy").(string),
+		AllProxy:   d.Get("all_proxy").(string),
+	}
+	// The environment default isn't validated by the schema
+	if err := validateSOCKSProxy(proxy.AllProxy); err != nil {
+		return nil, diag.Errorf("invalid 'all_proxy': %s", err)
+	}
+
+	helmEnv := make(map[string]string)
+	for name, value := range d.Get("helm_env").(map[string]interface{}) {
+		if name == "" || strings.Contains(name, "=") {
+			return nil, diag.Errorf("invalid 'helm_env' variable name: '%s'", name)
+		}
+		helmEnv[name] = value.(string)
+	}
 
 	tflog.Debug(ctx, "Init cache directory: "+cacheDir)
 	if err := os.MkdirAll(cacheDir, os.ModePerm); err != nil {
 		return nil, diag.Errorf("failed to create cache directory (try to use 'cache_dir' arg): %v", err)
 	}
+	if repositoryCacheDir != "" {
+		tflog.Debug(ctx, "Init repository cache directory: "+repositoryCacheDir)
+		if err := os.MkdirAll(repositoryCacheDir, os.ModePerm); err != nil {
+			return nil, diag.Errorf("failed to create 'repository_cache_dir': %v", err)
+		}
+	}
 
-	if helmBinPath == "" {
+	var indexTTL time.Duration
+	if indexCacheTTL != "" {
 		var err error
-		if helmBinPath, err = installHelmCLI(helmVersion, cacheDir); err != nil {
+		if indexTTL, err = time.ParseDuration(indexCacheTTL); err != nil {
+			return nil, diag.Errorf("invalid 'index_cache_ttl': %v", err)
+		}
+	}
+
+	var cmdTimeout time.Duration
+	if commandTimeout != "" {
+		var err error
+		if cmdTimeout, err = time.ParseDuration(commandTimeout); err != nil {
+			return nil, diag.Errorf("invalid 'command_timeout': %v", err)
+		}
+	}
+
+	if cacheMaxAge != "" {
+		maxAge, err := time.ParseDuration(cacheMaxAge)
+		if err != nil {
+			return nil, diag.Errorf("invalid 'cache_max_age': %v", err)
+		}
+		removed, err := cleanCache(cacheDir, maxAge, filepath.Join(cacheDir, "helm", helmVersion))
+		if err != nil {
+			tflog.Warn(ctx, "Failed to clean cache directory: "+err.Error())
+		}
+		for _, path := range removed {
+			tflog.Debug(ctx, "Removed expired cache entry: "+path)
+		}
+	}
+
+	// Store the inline CA bundle in the cache, so it can be passed to Helm as a file
+	if caBundle != "" {
+		caBundleFile = filepath.Join(cacheDir, "ca", generateHash(caBundle)+".pem")
+		if err := os.MkdirAll(filepath.Dir(caBundleFile), os.ModePerm); err != nil {
+			return nil, diag.Errorf("failed to create CA bundle directory: %v", err)
+		}
+		if err := os.WriteFile(caBundleFile, []byte(caBundle), 0600); err != nil {
+			return nil, diag.Errorf("failed to write CA bundle file: %v", err)
+		}
+	}
+
+	httpClient, err := newHTTPClient(proxy, caBundleFile)
+	if err != nil {
+		return nil, diag.FromErr(err)
+	}
+
+	if helmBinPath == "" && disableAutoInstall {
+		return nil, diag.Errorf("'helm_bin_path' is required since Helm auto-install is disabled by 'disable_auto_install'")
+	}
+	if helmBinPath == "" && offline {
+		helmBinPath = helmCachePath(cacheDir, helmVersion, runtime.GOOS, runtime.GOARCH)
+		if cachedPath, err := findHelmBinary(filepath.Dir(helmBinPath)); err == nil {
+			helmBinPath = cachedPath
+		} else {
+			return nil, diag.Diagnostics{{
+				Severity: diag.Error,
+				Summary:  "Helm binary is not found in the offline mode",
+				Detail: fmt.Sprintf("Helm binary '%s' isn't cached at '%s' and it can't be installed in the offline mode. "+
+					"Set 'helm_bin_path' or populate the cache by running the provider online with the same 'cache_dir' and 'helm_version'", helmVersion, helmBinPath),
+			}}
+		}
+	} else if helmBinPath == "" {
+		if helmBinPath, err = installHelmCLI(httpClient, proxy.env(), helmVersion, cacheDir); err != nil {
 			return nil, diag.FromErr(err)
 		}
 		tflog.Info(ctx, "Helm version: "+helmVersion+" is installed at: "+helmBinPath)
 	}
+	// The missing binary fails every Helm command with the confusing 'exec: not found'
+	if _, err := exec.LookPath(helmBinPath); err != nil {
+		return nil, diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  "Helm binary is not found",
+			Detail: fmt.Sprintf("Helm binary '%s' is not found or isn't executable: %v. "+
+				"Fix 'helm_bin_path' or remove the Helm binary cache '%s' to install Helm again", helmBinPath, err, filepath.Join(cacheDir, "helm")),
+		}}
+	}
 	tflog.Info(ctx, "Helm binary: "+helmBinPath)
 
 	kubeAuth := KubeAuth{
@@ -166,58 +728,335 @@ func configureProvider(ctx context.Context, d *schema.ResourceData) (interface{}
 		KubeToken:                 d.Get("kube_token").(string),
 		Kubeconfig:                d.Get("kubeconfig").(string),
 	}
+	for _, p := range d.Get("kubeconfig_paths").([]interface{}) {
+		path, _ := p.(string)
+		if _, err := os.Stat(path); err != nil {
+			return nil, diag.Errorf("kubeconfig path '%s' of 'kubeconfig_paths' is not found", path)
+		}
+		kubeAuth.KubeconfigPaths = append(kubeAuth.KubeconfigPaths, absPath(path))
+	}
+	// The KUBECONFIG default of 'kubeconfig' would override the merged files
+	if len(kubeAuth.KubeconfigPaths) > 0 {
+		kubeAuth.Kubeconfig = ""
+	}
+	// The KUBECONFIG default may be the list of the paths, it's kept as is then
+	if !strings.Contains(kubeAuth.Kubeconfig, string(os.PathListSeparator)) {
+		kubeAuth.Kubeconfig = absPath(kubeAuth.Kubeconfig)
+	}
+	kubeAuth.KubeCAFile = absPath(kubeAuth.KubeCAFile)
 
 	helmCmdFunc := func(args ...string) *exec.Cmd {
 		helmCmd := exec.Command(helmBinPath, args...)
-
-		if kubeAuth.KubeAPIServer != "" {
-			helmCmd.Args = append(helmCmd.Args, "--kube-apiserver", kubeAuth.KubeAPIServer)
+		// The proxy variables go last, so they aren't overridden by the user ones
+		helmCmd.Env = append(os.Environ(), helmPaths.env()...)
+		if len(kubeAuth.KubeconfigPaths) > 0 {
+			helmCmd.Env = append(helmCmd.Env, "KUBECONFIG="+strings.Join(kubeAuth.KubeconfigPaths, string(os.PathListSeparator)))
 		}
-		if kubeAuth.KubeAsUser != "" {
-			helmCmd.Args = append(helmCmd.Args, "--kube-as-user", kubeAuth.KubeAsUser)
+		if helmQuiet {
+			helmCmd.Env = append(helmCmd.Env, quietEnv...)
 		}
-		if kubeAuth.KubeAsGroup != "" {
-			helmCmd.Args = append(helmCmd.Args, "--kube-as-group", kubeAuth.KubeAsGroup)
+		helmCmd.Env = append(helmCmd.Env, mapToEnv(helmEnv)...)
+		helmCmd.Env = append(helmCmd.Env, proxy.env()...)
+
+		helmCmd.Args = append(helmCmd.Args, kubeAuth.helmArgs()...)
+
+		tflog.Debug(ctx, "Helm Command:"+redactArgs(helmCmd.Args))
+		return helmCmd
+	}
+
+	config := &ProviderConfig{
+		HelmBinPath:         helmBinPath,
+		GitBinPath:          gitGitBinPath,
+		HelmVersion:         helmVersion,
+		CacheDir:            cacheDir,
+		KubeAuth:            kubeAuth,
+		Proxy:               proxy,
+		HelmPaths:           helmPaths,
+		HelmEnv:             helmEnv,
+		CABundleFile:        caBundleFile,
+		LogFormat:           logFormat,
+		Offline:             offline,
+		PlanDiff:            planDiff,
+		IDIncludesContext:   idIncludesContext,
+		HelmQuiet:           helmQuiet,
+		RequireGitReference: requireGitReference,
+		ReleaseNamePrefix:   d.Get("release_name_prefix").(string),
+		ReleaseNameSuffix:   d.Get("release_name_suffix").(string),
+		Repository:          repositoryAuth,
+		IndexCacheTTL:       indexTTL,
+		CommandTimeout:      cmdTimeout,
+		HTTPClient:          httpClient,
+		HelmCmd:             helmCmdFunc,
+	}
+
+	if minHelmVersion != "" {
+		constraint := minHelmVersion
+		if _, err := version.NewVersion(minHelmVersion); err == nil {
+			constraint = ">= " + minHelmVersion
 		}
-		if kubeAuth.KubeCAFile != "" {
-			helmCmd.Args = append(helmCmd.Args, "--kube-ca-file", kubeAuth.KubeCAFile)
+		if err := config.checkHelmVersion("min_helm_version", constraint); err != nil {
+			return nil, diag.FromErr(err)
 		}
-		if kubeAuth.KubeContext != "" {
-			helmCmd.Args = append(helmCmd.Args, "--kube-context", kubeAuth.KubeContext)
+		detected, _ := config.detectHelmVersion()
+		tflog.Info(ctx, "Helm binary version: "+detected.Original())
+	}
+
+	return config, nil
+}
+
+// detectHelmVersion returns the version of the used Helm binary, it's detected only once
+func (c *ProviderConfig) detectHelmVersion() (*version.Version, error) {
+	c.helmVersionOnce.Do(func() {
+		output, err := c.output(c.HelmCmd("version", "--template", "{{.Version}}"))
+		if err != nil {
+			c.helmSemVerErr = fmt.Errorf("failed to detect Helm version: %v", err)
+			return
 		}
-		if kubeAuth.KubeInsecureSkipTLSVerify {
-			helmCmd.Args = append(helmCmd.Args, "--kube-insecure-skip-tls-verify")
+		c.helmSemVer, c.helmSemVerErr = parseHelmVersion(string(output))
+	})
+
+	return c.helmSemVer, c.helmSemVerErr
+}
+
+// repositoryAuth returns the chart repository authentication of the release, the provider defaults are used for the unset values.
+// The release credentials override the provider ones together, so the release username isn't mixed with the provider password
+func (c *ProviderConfig) repositoryAuth(username, password, caFile string) RepositoryAuth {
+	auth := c.Repository
+	if username != "" || password != "" {
+		auth.Username, auth.Password = username, password
+	}
+	if caFile != "" {
+		auth.CAFile = caFile
+	}
+	if auth.CAFile == "" {
+		auth.CAFile = c.CABundleFile
+	}
+	auth.CAFile = absPath(auth.CAFile)
+	return auth
+}
+
+// lockRelease serializes the operations on the same Helm release, the returned function releases the lock
+func (c *ProviderConfig) lockRelease(namespace, name string) (unlock func()) {
+	key := namespace + "/" + name
+
+	c.releaseLocksMu.Lock()
+	if c.releaseLocks == nil {
+		c.releaseLocks = make(map[string]*sync.Mutex)
+	}
+	lock, ok := c.releaseLocks[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		c.releaseLocks[key] = lock
+	}
+	c.releaseLocksMu.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// logHelmOperation emits the structured event of the finished Helm operation if the JSON log format is used
+func (c *ProviderConfig) logHelmOperation(ctx context.Context, namespace, name string, cmd *exec.Cmd, duration time.Duration, err error) {
+	if c.LogFormat != "json" {
+		return
+	}
+
+	exitCode := 0
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	} else if err != nil {
+		exitCode = -1
+	}
+	_, failureClass := helmExitStatus(err)
+
+	tflog.Info(ctx, "Helm operation finished", map[string]interface{}{
+		"helm_command":      redactArgs(cmd.Args),
+		"duration_seconds":  duration.Seconds(),
+		"exit_code":         exitCode,
+		"failure_class":     failureClass,
+		"release_name":      name,
+		"release_namespace": namespace,
+	})
+}
+
+// checkHelmVersion ensures the Helm binary satisfies the version constraint required by the feature
+func (c *ProviderConfig) checkHelmVersion(feature, constraint string) error {
+	helmVersion, err := c.detectHelmVersion()
+	if err != nil {
+		return err
+	}
+
+	constraints, err := version.NewConstraint(constraint)
+	if err != nil {
+		return fmt.Errorf("invalid Helm version constraint '%s': %v", constraint, err)
+	}
+	if !constraints.Check(helmVersion) {
+		return fmt.Errorf("'%s' requires Helm version %s, current version: %s", feature, constraint, helmVersion.Original())
+	}
+
+	return nil
+}
+
+// releaseName returns the Helm release name of the resource name with the provider prefix and suffix
+func (c *ProviderConfig) releaseName(name string) string {
+	return c.ReleaseNamePrefix + name + c.ReleaseNameSuffix
+}
+
+// validateReleaseNameAffix validates the release name prefix or suffix, the combined name is validated on plan
+func validateReleaseNameAffix(val interface{}, key string) (warns []string, errs []error) {
+	if affix := val.(string); affix != "" && !releaseNameAffixRegexp.MatchString(affix) {
+		errs = append(errs, fmt.Errorf("%q: must consist of lower case alphanumeric characters, '-' or '.', got: %s", key, affix))
+	}
+	return
+}
+
+// releaseID returns the ID of the Helm release, it includes the kube context if it's enabled and the context is known
+func (c *ProviderConfig) releaseID(namespace, name string) string {
+	if c.IDIncludesContext {
+		if contextName := c.KubeAuth.contextName(); contextName != "" {
+			return fmt.Sprintf("%s/%s/%s", contextName, namespace, name)
 		}
-		if kubeAuth.KubeTLSServerName != "" {
-			helmCmd.Args = append(helmCmd.Args, "--kube-tls-server-name", kubeAuth.KubeTLSServerName)
+	}
+	return fmt.Sprintf("%s/%s", namespace, name)
+}
+
+// runCommand runs the command and kills it if it runs longer than the timeout, zero timeout disables the limit.
+// The deadline starts with the command, so the preparation of the operation, e.g. the chart download, doesn't count against it
+func runCommand(cmd *exec.Cmd, timeout time.Duration) error {
+	if timeout <= 0 {
+		return cmd.Run()
+	}
+
+	// Don't wait for the output of the killed command children holding its pipes
+	cmd.WaitDelay = time.Second
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	timer := time.AfterFunc(timeout, func() {
+		cmd.Process.Kill()
+	})
+	defer timer.Stop()
+	return cmd.Wait()
+}
+
+// commandOutput runs the command with runCommand and returns its standard output
+func commandOutput(cmd *exec.Cmd, timeout time.Duration) ([]byte, error) {
+	if cmd.Stdout != nil {
+		return nil, fmt.Errorf("exec: Stdout already set")
+	}
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	err := runCommand(cmd, timeout)
+	return stdout.Bytes(), err
+}
+
+// commandCombinedOutput runs the command with runCommand and returns its combined standard output and standard error
+func commandCombinedOutput(cmd *exec.Cmd, timeout time.Duration) ([]byte, error) {
+	if cmd.Stdout != nil || cmd.Stderr != nil {
+		return nil, fmt.Errorf("exec: Stdout or Stderr already set")
+	}
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := runCommand(cmd, timeout)
+	return output.Bytes(), err
+}
+
+// run runs the command limited by the provider command timeout
+func (c *ProviderConfig) run(cmd *exec.Cmd) error {
+	return runCommand(cmd, c.CommandTimeout)
+}
+
+// output runs the command limited by the provider command timeout and returns its standard output
+func (c *ProviderConfig) output(cmd *exec.Cmd) ([]byte, error) {
+	return commandOutput(cmd, c.CommandTimeout)
+}
+
+// combinedOutput runs the command limited by the provider command timeout and returns its combined output
+func (c *ProviderConfig) combinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	return commandCombinedOutput(cmd, c.CommandTimeout)
+}
+
+// commandTimeoutDiags explains the commands killed by the command timeout, since the error is only 'signal: killed' otherwise
+func commandTimeoutDiags(diags diag.Diagnostics, timeout, elapsed time.Duration) diag.Diagnostics {
+	if timeout <= 0 || elapsed < timeout {
+		return diags
+	}
+	for i := range diags {
+		if diags[i].Severity == diag.Error && strings.Contains(diags[i].Summary, "signal: killed") {
+			diags[i].Detail = strings.TrimSpace(fmt.Sprintf("The command is killed after running longer than 'command_timeout' (%s), the release 'timeout' only limits the Helm in-cluster waits\n%s", timeout, diags[i].Detail))
 		}
-		if kubeAuth.KubeToken != "" {
-			helmCmd.Args = append(helmCmd.Args, "--kube-token", kubeAuth.KubeToken)
+	}
+	return diags
+}
+
+// parseHelmVersion parses the 'helm version' output, e.g. 'v3.14.2' or 'v3.14.2+gc309b6f'
+func parseHelmVersion(output string) (*version.Version, error) {
+	v, err := version.NewVersion(strings.TrimSpace(output))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Helm version '%s': %v", strings.TrimSpace(output), err)
+	}
+	return v, nil
+}
+
+// cacheGCDirs are the cache subdirectories holding the downloaded artifacts
+var cacheGCDirs = []string{"helm", "repos", "values", "postrender", "index"}
+
+// cacheFresh checks whether the cached file exists and was modified within the ttl, nothing is fresh if ttl isn't set
+func cacheFresh(path string, ttl time.Duration) bool {
+	if ttl <= 0 {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && time.Since(info.ModTime()) < ttl
+}
+
+// cleanCache removes the cached artifacts which weren't modified for maxAge, the keep paths are skipped
+func cleanCache(cacheDir string, maxAge time.Duration, keep ...string) (removed []string, err error) {
+	threshold := time.Now().Add(-maxAge)
+	skip := make(map[string]bool)
+	for _, path := range keep {
+		skip[filepath.Clean(path)] = true
+	}
+
+	for _, dir := range cacheGCDirs {
+		entries, err := os.ReadDir(filepath.Join(cacheDir, dir))
+		if os.IsNotExist(err) {
+			continue
 		}
-		if kubeAuth.Kubeconfig != "" {
-			helmCmd.Args = append(helmCmd.Args, "--kubeconfig", kubeAuth.Kubeconfig)
+		if err != nil {
+			return removed, fmt.Errorf("failed to read cache directory: %v", err)
 		}
 
-		tflog.Debug(ctx, "Helm Command:"+strings.Join(helmCmd.Args, " "))
-		return helmCmd
+		for _, entry := range entries {
+			path := filepath.Join(cacheDir, dir, entry.Name())
+			if skip[path] {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil || !info.ModTime().Before(threshold) {
+				continue
+			}
+			if err := os.RemoveAll(path); err != nil {
+				return removed, fmt.Errorf("failed to remove cache entry: %v", err)
+			}
+			removed = append(removed, path)
+		}
 	}
 
-	return &ProviderConfig{
-		HelmBinPath: helmBinPath,
-		GitBinPath:  gitGitBinPath,
-		HelmVersion: helmVersion,
-		CacheDir:    cacheDir,
-		KubeAuth:    kubeAuth,
-		HelmCmd:     helmCmdFunc,
-	}, nil
+	return removed, nil
+}
+
+// helmCachePath returns the cached Helm binary path, the platform is included since the cache may be shared across the runners
+func helmCachePath(cacheDir, helmVersion, goos, goarch string) string {
+	return filepath.Join(cacheDir, "helm", helmVersion, goos+"_"+goarch, "helm")
 }
 
-func installHelmCLI(helmVersion string, cacheDir string) (helmBinPath string, err error) {
-	helmDir := filepath.Join(cacheDir, "helm", helmVersion)
-	helmBinPath = filepath.Join(helmDir, "helm")
-	if _, err := os.Stat(helmBinPath); err == nil {
-		log.Printf("Using cached Helm binary: %s", helmBinPath)
-		return helmBinPath, nil
+// installHelmCLI installs Helm binary into the cache directory, env is passed to the installation script
+func installHelmCLI(httpClient *http.Client, env []string, helmVersion string, cacheDir string) (helmBinPath string, err error) {
+	helmDir := filepath.Dir(helmCachePath(cacheDir, helmVersion, runtime.GOOS, runtime.GOARCH))
+	if cachedPath, err := findHelmBinary(helmDir); err == nil {
+		log.Printf("Using cached Helm binary: %s", cachedPath)
+		return cachedPath, nil
 	}
 
 	if err := os.MkdirAll(helmDir, os.ModePerm); err != nil {
@@ -226,7 +1065,7 @@ func installHelmCLI(helmVersion string, cacheDir string) (helmBinPath string, er
 
 	installScriptPath := filepath.Join(helmDir, "get_helm.sh")
 
-	if err := downloadFile(GET_HELM_URL, installScriptPath); err != nil {
+	if err := downloadFile(httpClient, GET_HELM_URL, installScriptPath); err != nil {
 		return "", fmt.Errorf("failed to download Helm installation script: %v", err)
 	}
 
@@ -240,16 +1079,142 @@ func installHelmCLI(helmVersion string, cacheDir string) (helmBinPath string, er
 		"HELM_INSTALL_DIR="+helmDir,
 		"USE_SUDO=false",
 	)
+	installHelmCmd.Env = append(installHelmCmd.Env, env...)
 	output, err := installHelmCmd.CombinedOutput()
 	if err != nil {
-		return "", fmt.Errorf("failed to install Helm: %v\nOutput: %s", err, output)
+		return "", fmt.Errorf("failed to install Helm: %v\nOutput: %s", err, stripANSI(string(output)))
+	}
+
+	// The installer layout may change, e.g. the binary is placed into the '<os>-<arch>' subdirectory
+	if helmBinPath, err = findHelmBinary(helmDir); err != nil {
+		return "", fmt.Errorf("failed to install Helm: %v\nOutput: %s", err, stripANSI(string(output)))
 	}
 
 	return helmBinPath, nil
 }
 
-func downloadFile(url, destPath string) error {
-	resp, err := http.Get(url)
+// findHelmBinary returns the path of the 'helm' or 'helm.exe' binary within the directory, the top level one is preferred
+func findHelmBinary(dir string) (string, error) {
+	for _, name := range []string{"helm", "helm.exe"} {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && !info.IsDir() {
+			return filepath.Join(dir, name), nil
+		}
+	}
+
+	found := ""
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && (entry.Name() == "helm" || entry.Name() == "helm.exe") {
+			found = path
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to search Helm binary in '%s': %v", dir, err)
+	}
+	if found == "" {
+		return "", fmt.Errorf("Helm binary is not found in '%s'", dir)
+	}
+
+	return found, nil
+}
+
+// newHTTPClient creates HTTP client for the provider downloads, the CA bundle is trusted along with the system CAs
+func newHTTPClient(proxy ProxyConfig, caBundleFile string) (*http.Client, error) {
+	proxyFunc := (&httpproxy.Config{
+		HTTPProxy:  proxy.HTTPProxy,
+		HTTPSProxy: proxy.HTTPSProxy,
+		NoProxy:    proxy.NoProxy,
+	}).ProxyFunc()
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+
+	if proxy.AllProxy != "" {
+		dialContext, err := socksDialContext(proxy.AllProxy, proxy.NoProxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.DialContext = dialContext
+	}
+
+	if caBundleFile != "" {
+		caBundle, err := os.ReadFile(caBundleFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %v", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(caBundle) {
+			return nil, fmt.Errorf("failed to parse CA bundle '%s': no PEM certificates found", caBundleFile)
+		}
+
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// validateSOCKSProxy checks that the proxy URL is the SOCKS5 one, the empty URL is valid
+func validateSOCKSProxy(proxyURL string) error {
+	if proxyURL == "" {
+		return nil
+	}
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return fmt.Errorf("invalid proxy URL: %v", err)
+	}
+	if u.Scheme != "socks5" && u.Scheme != "socks5h" {
+		return fmt.Errorf("expected the 'socks5://' or 'socks5h://' proxy URL, got: %s", proxyURL)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("proxy URL '%s' has no host", proxyURL)
+	}
+	return nil
+}
+
+// socksDialContext returns the dial function connecting through the SOCKS5 proxy, the no_proxy hosts are dialed directly
+func socksDialContext(proxyURL, noProxy string) (func(ctx context.Context, network, addr string) (net.Conn, error), error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'all_proxy' URL: %v", err)
+	}
+	socksDialer, err := xproxy.FromURL(u, xproxy.Direct)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the SOCKS5 dialer: %v", err)
+	}
+
+	dialer := xproxy.NewPerHost(socksDialer, xproxy.Direct)
+	dialer.AddFromString(noProxy)
+	return dialer.DialContext, nil
+}
+
+// insecureHTTPClient returns a copy of the HTTP client which doesn't verify certificates
+func insecureHTTPClient(client *http.Client) *http.Client {
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		return client
+	}
+
+	transport = transport.Clone()
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.InsecureSkipVerify = true
+
+	return &http.Client{Transport: transport, Timeout: client.Timeout}
+}
+
+func downloadFile(client *http.Client, url, destPath string) error {
+	resp, err := client.Get(url)
 	if err != nil {
 		return fmt.Errorf("failed to download file: %v", err)
 	}
@@ -0,0 +1,1020 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// fakeHelmBin creates a fake Helm binary printing the given version
+func fakeHelmBin(t *testing.T, helmVersion string) string {
+	helmBinPath := filepath.Join(t.TempDir(), "helm")
+	script := "#!/bin/sh\necho " + helmVersion + "\n"
+	if err := os.WriteFile(helmBinPath, []byte(script), 0700); err != nil {
+		t.Fatalf("failed to create fake Helm binary: %v", err)
+	}
+	return helmBinPath
+}
+
+// TestParseHelmVersion tests the parseHelmVersion function
+func TestParseHelmVersion(t *testing.T) {
+	tests := map[string]string{
+		"v3.14.2":         "3.14.2",
+		"v3.14.2\n":       "3.14.2",
+		"v3.9.4+gdbc6d8e": "3.9.4",
+		"3.17.0-rc.1":     "3.17.0-rc.1",
+	}
+
+	for output, expected := range tests {
+		v, err := parseHelmVersion(output)
+		if err != nil {
+			t.Fatalf("parseHelmVersion failed for %q: %v", output, err)
+		}
+		if v.String() != expected && v.Core().String() != expected {
+			t.Errorf("unexpected version for %q: %s", output, v)
+		}
+	}
+
+	if _, err := parseHelmVersion("unknown"); err == nil {
+		t.Errorf("expected invalid version to fail")
+	}
+}
+
+// TestCheckHelmVersion tests the checkHelmVersion function
+func TestCheckHelmVersion(t *testing.T) {
+	cfg := &ProviderConfig{
+		HelmCmd: func(args ...string) *exec.Cmd {
+			return exec.Command("echo", "v3.14.2")
+		},
+	}
+
+	if err := cfg.checkHelmVersion("feature", ">= 3.10.0"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := cfg.checkHelmVersion("feature", ">= 3.17.0"); err == nil {
+		t.Errorf("expected version constraint to fail")
+	}
+}
+
+// TestConfigureProviderMinHelmVersion tests the min_helm_version check
+func TestConfigureProviderMinHelmVersion(t *testing.T) {
+	tests := []struct {
+		minHelmVersion string
+		valid          bool
+	}{
+		{"", true},
+		{"3.10.0", true},
+		{"v3.14.2", true},
+		{">= 3.10, < 4", true},
+		{"3.15.0", false},
+		{"~> 3.9.0", false},
+	}
+
+	for _, tt := range tests {
+		d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+			"helm_bin_path":    fakeHelmBin(t, "v3.14.2"),
+			"cache_dir":        t.TempDir(),
+			"min_helm_version": tt.minHelmVersion,
+		})
+
+		_, diags := configureProvider(context.Background(), d)
+		if diags.HasError() == tt.valid {
+			t.Errorf("unexpected result for min_helm_version %q: %v", tt.minHelmVersion, diags)
+		}
+		if !tt.valid && !strings.Contains(diags[0].Summary, "current version: v3.14.2") {
+			t.Errorf("unexpected error: %s", diags[0].Summary)
+		}
+	}
+}
+
+// TestConfigureProviderOffline tests that the offline mode uses only the cached Helm binary
+func TestConfigureProviderOffline(t *testing.T) {
+	cacheDir := t.TempDir()
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"helm_version": "v3.14.2",
+		"cache_dir":    cacheDir,
+		"offline":      true,
+	})
+
+	_, diags := configureProvider(context.Background(), d)
+	if !diags.HasError() {
+		t.Fatalf("expected offline mode without the cached Helm binary to fail")
+	}
+	helmBinPath := helmCachePath(cacheDir, "v3.14.2", runtime.GOOS, runtime.GOARCH)
+	if !strings.Contains(diags[0].Detail, helmBinPath) || !strings.Contains(diags[0].Detail, "helm_bin_path") {
+		t.Errorf("unexpected error: %s: %s", diags[0].Summary, diags[0].Detail)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(helmBinPath), os.ModePerm); err != nil {
+		t.Fatalf("failed to create the cache directory: %v", err)
+	}
+	if err := os.WriteFile(helmBinPath, []byte("#!/bin/sh\necho v3.14.2\n"), 0700); err != nil {
+		t.Fatalf("failed to create the cached Helm binary: %v", err)
+	}
+
+	m, diags := configureProvider(context.Background(), d)
+	if diags.HasError() {
+		t.Fatalf("configureProvider failed: %v", diags)
+	}
+	if cfg := m.(*ProviderConfig); cfg.HelmBinPath != helmBinPath || !cfg.Offline {
+		t.Errorf("unexpected config: Helm binary %s, offline %v", cfg.HelmBinPath, cfg.Offline)
+	}
+}
+
+// TestConfigureProviderDisableAutoInstall tests that Helm binary path is required when the auto-install is disabled
+func TestConfigureProviderDisableAutoInstall(t *testing.T) {
+	cacheDir := t.TempDir()
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"cache_dir":            cacheDir,
+		"disable_auto_install": true,
+	})
+
+	_, diags := configureProvider(context.Background(), d)
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, "helm_bin_path") {
+		t.Fatalf("expected missing 'helm_bin_path' error, got: %v", diags)
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "helm")); err == nil {
+		t.Errorf("Helm binary must not be installed when the auto-install is disabled")
+	}
+
+	helmBinPath := filepath.Join(t.TempDir(), "helm")
+	if err := os.WriteFile(helmBinPath, []byte("#!/bin/sh\necho v3.14.2\n"), 0700); err != nil {
+		t.Fatalf("failed to create fake Helm binary: %v", err)
+	}
+	d = schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"cache_dir":            cacheDir,
+		"helm_bin_path":        helmBinPath,
+		"disable_auto_install": true,
+	})
+
+	m, diags := configureProvider(context.Background(), d)
+	if diags.HasError() {
+		t.Fatalf("configureProvider failed: %v", diags)
+	}
+	if cfg := m.(*ProviderConfig); cfg.HelmBinPath != helmBinPath {
+		t.Errorf("unexpected Helm binary: %s, expected: %s", cfg.HelmBinPath, helmBinPath)
+	}
+}
+
+// TestConfigureProviderMissingHelmBinary tests that the missing or non-executable Helm binary fails with the helpful error
+func TestConfigureProviderMissingHelmBinary(t *testing.T) {
+	nonExecutable := filepath.Join(t.TempDir(), "helm")
+	if err := os.WriteFile(nonExecutable, []byte("#!/bin/sh\necho v3.14.2\n"), 0600); err != nil {
+		t.Fatalf("failed to create fake Helm binary: %v", err)
+	}
+
+	for _, helmBinPath := range []string{filepath.Join(t.TempDir(), "missing", "helm"), nonExecutable} {
+		d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+			"helm_bin_path": helmBinPath,
+			"cache_dir":     t.TempDir(),
+		})
+
+		_, diags := configureProvider(context.Background(), d)
+		if !diags.HasError() {
+			t.Fatalf("expected missing Helm binary '%s' to fail", helmBinPath)
+		}
+		if diags[0].Summary != "Helm binary is not found" || !strings.Contains(diags[0].Detail, helmBinPath) || !strings.Contains(diags[0].Detail, "helm_bin_path") {
+			t.Errorf("unexpected error: %s: %s", diags[0].Summary, diags[0].Detail)
+		}
+	}
+}
+
+// TestConfigureProviderRepositoryAuth tests the provider default chart repository authentication
+func TestConfigureProviderRepositoryAuth(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, []byte("ca"), 0600); err != nil {
+		t.Fatalf("failed to create CA file: %v", err)
+	}
+
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"helm_bin_path":       fakeHelmBin(t, "v3.14.2"),
+		"cache_dir":           t.TempDir(),
+		"repository_username": "user",
+		"repository_password": "secret",
+		"repository_ca_file":  caFile,
+	})
+	m, diags := configureProvider(context.Background(), d)
+	if diags.HasError() {
+		t.Fatalf("configureProvider failed: %v", diags)
+	}
+	if auth := m.(*ProviderConfig).Repository; auth != (RepositoryAuth{Username: "user", Password: "secret", CAFile: caFile}) {
+		t.Errorf("unexpected repository authentication: %+v", auth)
+	}
+
+	d = schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"helm_bin_path":      fakeHelmBin(t, "v3.14.2"),
+		"cache_dir":          t.TempDir(),
+		"repository_ca_file": filepath.Join(t.TempDir(), "missing.pem"),
+	})
+	if _, diags := configureProvider(context.Background(), d); !diags.HasError() || !strings.Contains(diags[0].Summary, "repository_ca_file") {
+		t.Errorf("expected missing 'repository_ca_file' error, got: %v", diags)
+	}
+}
+
+// TestKubeAuthHelmArgs tests the Helm flags of the kube auth override combinations
+func TestKubeAuthHelmArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		auth     KubeAuth
+		expected []string
+	}{
+		{"kubeconfig only", KubeAuth{Kubeconfig: "/kubeconfig"}, []string{"--kubeconfig", "/kubeconfig"}},
+		{"context override", KubeAuth{Kubeconfig: "/kubeconfig", KubeContext: "prod"}, []string{"--kube-context", "prod", "--kubeconfig", "/kubeconfig"}},
+		{"token override", KubeAuth{Kubeconfig: "/kubeconfig", KubeToken: "token"}, []string{"--kube-token", "token", "--kubeconfig", "/kubeconfig"}},
+		{"apiserver override", KubeAuth{Kubeconfig: "/kubeconfig", KubeAPIServer: "https://localhost:6443", KubeTLSServerName: "kubernetes"}, []string{"--kube-apiserver", "https://localhost:6443", "--kube-tls-server-name", "kubernetes", "--kubeconfig", "/kubeconfig"}},
+		{"CA file override", KubeAuth{Kubeconfig: "/kubeconfig", KubeCAFile: "/ca.pem"}, []string{"--kube-ca-file", "/ca.pem", "--kubeconfig", "/kubeconfig"}},
+		{"insecure over CA file", KubeAuth{Kubeconfig: "/kubeconfig", KubeCAFile: "/ca.pem", KubeInsecureSkipTLSVerify: true}, []string{"--kube-insecure-skip-tls-verify", "--kubeconfig", "/kubeconfig"}},
+		{"impersonation", KubeAuth{KubeAsUser: "admin", KubeAsGroup: "system:masters"}, []string{"--kube-as-user", "admin", "--kube-as-group", "system:masters"}},
+		{"no kubeconfig", KubeAuth{}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if args := tt.auth.helmArgs(); !reflect.DeepEqual(args, tt.expected) {
+				t.Errorf("unexpected Helm flags: %v, expected: %v", args, tt.expected)
+			}
+		})
+	}
+}
+
+// TestConfigureProviderRequireGitReference tests that 'require_git_reference' is passed to the provider config
+func TestConfigureProviderRequireGitReference(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"helm_bin_path":         fakeHelmBin(t, "v3.14.2"),
+		"cache_dir":             t.TempDir(),
+		"require_git_reference": true,
+	})
+	m, diags := configureProvider(context.Background(), d)
+	if diags.HasError() {
+		t.Fatalf("configureProvider failed: %v", diags)
+	}
+	if !m.(*ProviderConfig).RequireGitReference {
+		t.Errorf("expected 'require_git_reference' to be set")
+	}
+	if !m.(*ProviderConfig).withHelmHome(t.TempDir()).RequireGitReference {
+		t.Errorf("expected 'require_git_reference' to be kept by the Helm home config")
+	}
+}
+
+// TestConfigureProviderReleaseNameAffixes tests the release name prefix and suffix
+func TestConfigureProviderReleaseNameAffixes(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"helm_bin_path":       fakeHelmBin(t, "v3.14.2"),
+		"cache_dir":           t.TempDir(),
+		"release_name_prefix": "team-a-",
+		"release_name_suffix": "-prod",
+	})
+	m, diags := configureProvider(context.Background(), d)
+	if diags.HasError() {
+		t.Fatalf("configureProvider failed: %v", diags)
+	}
+	if name := m.(*ProviderConfig).withHelmHome(t.TempDir()).releaseName("nginx"); name != "team-a-nginx-prod" {
+		t.Errorf("unexpected release name: %s", name)
+	}
+
+	for _, affix := range []string{"Team_A", "team a"} {
+		if _, errs := Provider().Schema["release_name_prefix"].ValidateFunc(affix, "release_name_prefix"); len(errs) == 0 {
+			t.Errorf("expected invalid release_name_prefix to fail: %s", affix)
+		}
+	}
+}
+
+// TestRunCommandTimeout tests that the command timeout starts with the command instead of its creation
+func TestRunCommandTimeout(t *testing.T) {
+	cmd := exec.Command("sh", "-c", "sleep 0.1")
+	time.Sleep(300 * time.Millisecond)
+	if err := runCommand(cmd, 200*time.Millisecond); err != nil {
+		t.Errorf("unexpected error of the command run within the timeout: %v", err)
+	}
+
+	output, err := commandOutput(exec.Command("sh", "-c", "echo started; exec sleep 30"), 200*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "signal: killed") {
+		t.Errorf("expected the command to be killed, got: %v", err)
+	}
+	if string(output) != "started\n" {
+		t.Errorf("unexpected output of the killed command: %q", output)
+	}
+}
+
+// TestConfigureProviderCommandTimeout tests that the hung Helm command is killed after the command timeout
+func TestConfigureProviderCommandTimeout(t *testing.T) {
+	helmBinPath := filepath.Join(t.TempDir(), "helm")
+	if err := os.WriteFile(helmBinPath, []byte("#!/bin/sh\nexec sleep 30\n"), 0700); err != nil {
+		t.Fatalf("failed to create fake Helm binary: %v", err)
+	}
+
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"helm_bin_path":   helmBinPath,
+		"cache_dir":       t.TempDir(),
+		"command_timeout": "200ms",
+	})
+	m, diags := configureProvider(context.Background(), d)
+	if diags.HasError() {
+		t.Fatalf("configureProvider failed: %v", diags)
+	}
+	if timeout := m.(*ProviderConfig).CommandTimeout; timeout != 200*time.Millisecond {
+		t.Errorf("unexpected command timeout: %s", timeout)
+	}
+
+	rd := schema.TestResourceDataRaw(t, resourceHelmRelease().Schema, map[string]interface{}{
+		"name":      "test-helm-release",
+		"namespace": "test-namespace",
+	})
+	start := time.Now()
+	diags = resourceHelmRelease().ReadContext(context.Background(), rd, m)
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("hung Helm command isn't killed, elapsed: %s", elapsed)
+	}
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, "signal: killed") {
+		t.Fatalf("expected killed Helm command error, got: %v", diags)
+	}
+	if !strings.Contains(diags[0].Detail, "'command_timeout' (200ms)") {
+		t.Errorf("unexpected error detail: %s", diags[0].Detail)
+	}
+
+	// The command timeout is validated
+	if _, errs := Provider().Schema["command_timeout"].ValidateFunc("forever", "command_timeout"); len(errs) == 0 {
+		t.Errorf("expected invalid command_timeout to fail")
+	}
+}
+
+// TestCommandTimeoutDiags tests that only the commands killed after the command timeout are explained
+func TestCommandTimeoutDiags(t *testing.T) {
+	killed := func() diag.Diagnostics { return diag.Errorf("failed to run Helm: signal: killed") }
+
+	if diags := commandTimeoutDiags(killed(), time.Minute, 2*time.Minute); !strings.Contains(diags[0].Detail, "'command_timeout' (1m0s)") {
+		t.Errorf("expected command timeout detail, got: %q", diags[0].Detail)
+	}
+	if diags := commandTimeoutDiags(killed(), time.Minute, time.Second); diags[0].Detail != "" {
+		t.Errorf("unexpected detail of the command killed before the timeout: %q", diags[0].Detail)
+	}
+	if diags := commandTimeoutDiags(killed(), 0, time.Hour); diags[0].Detail != "" {
+		t.Errorf("unexpected detail without the command timeout: %q", diags[0].Detail)
+	}
+	if diags := commandTimeoutDiags(diag.Errorf("failed to run Helm: exit status 1"), time.Minute, 2*time.Minute); diags[0].Detail != "" {
+		t.Errorf("unexpected detail of the failed command: %q", diags[0].Detail)
+	}
+}
+
+// TestDownloadFileProxy tests that downloadFile goes through the configured proxy
+func TestDownloadFileProxy(t *testing.T) {
+	var proxiedURL string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedURL = r.URL.String()
+		w.Write([]byte("#!/bin/sh"))
+	}))
+	defer proxy.Close()
+
+	client, err := newHTTPClient(ProxyConfig{HTTPProxy: proxy.URL, NoProxy: "internal.example.com"}, "")
+	if err != nil {
+		t.Fatalf("newHTTPClient failed: %v", err)
+	}
+	destPath := filepath.Join(t.TempDir(), "get_helm.sh")
+	if err := downloadFile(client, "http://example.com/get-helm-3", destPath); err != nil {
+		t.Fatalf("downloadFile failed: %v", err)
+	}
+
+	if proxiedURL != "http://example.com/get-helm-3" {
+		t.Errorf("unexpected proxied URL: %s", proxiedURL)
+	}
+	if content, _ := os.ReadFile(destPath); string(content) != "#!/bin/sh" {
+		t.Errorf("unexpected file content: %s", content)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://internal.example.com/get-helm-3", nil)
+	if proxyURL, err := client.Transport.(*http.Transport).Proxy(req); err != nil || proxyURL != nil {
+		t.Errorf("unexpected proxy for the no_proxy host: %v, %v", proxyURL, err)
+	}
+}
+
+// socksServer starts the minimal SOCKS5 proxy without authentication and counts the proxied connections
+func socksServer(t *testing.T, connections *int32) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start SOCKS5 proxy: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				// The greeting and the CONNECT request of the IPv4 address
+				greeting := make([]byte, 3)
+				if _, err := io.ReadFull(conn, greeting); err != nil {
+					return
+				}
+				conn.Write([]byte{5, 0})
+				request := make([]byte, 10)
+				if _, err := io.ReadFull(conn, request); err != nil || request[3] != 1 {
+					return
+				}
+				target, err := net.Dial("tcp", net.JoinHostPort(net.IP(request[4:8]).String(), fmt.Sprint(int(request[8])<<8|int(request[9]))))
+				if err != nil {
+					return
+				}
+				defer target.Close()
+				atomic.AddInt32(connections, 1)
+				conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+				go io.Copy(target, conn)
+				io.Copy(conn, target)
+			}()
+		}
+	}()
+
+	return "socks5://" + listener.Addr().String()
+}
+
+// TestDownloadFileSOCKSProxy tests that the HTTP client dials through the SOCKS5 proxy
+func TestDownloadFileSOCKSProxy(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("#!/bin/sh"))
+	}))
+	defer ts.Close()
+
+	var connections int32
+	proxyURL := socksServer(t, &connections)
+	destPath := filepath.Join(t.TempDir(), "get_helm.sh")
+
+	client, err := newHTTPClient(ProxyConfig{AllProxy: proxyURL}, "")
+	if err != nil {
+		t.Fatalf("newHTTPClient failed: %v", err)
+	}
+	if err := downloadFile(client, ts.URL+"/get-helm-3", destPath); err != nil {
+		t.Fatalf("downloadFile failed: %v", err)
+	}
+	if atomic.LoadInt32(&connections) != 1 {
+		t.Errorf("expected the download through the SOCKS5 proxy, got %d connections", connections)
+	}
+
+	// The no_proxy host is dialed directly
+	client, err = newHTTPClient(ProxyConfig{AllProxy: proxyURL, NoProxy: "127.0.0.1"}, "")
+	if err != nil {
+		t.Fatalf("newHTTPClient failed: %v", err)
+	}
+	if err := downloadFile(client, ts.URL+"/get-helm-3", destPath); err != nil {
+		t.Fatalf("downloadFile failed: %v", err)
+	}
+	if atomic.LoadInt32(&connections) != 1 {
+		t.Errorf("unexpected connection through the SOCKS5 proxy for the no_proxy host")
+	}
+
+	env := strings.Join(ProxyConfig{AllProxy: proxyURL, HTTPProxy: "http://proxy:3128"}.env(), "\n")
+	for _, expected := range []string{"ALL_PROXY=" + proxyURL, "HTTPS_PROXY=" + proxyURL, "HTTP_PROXY=http://proxy:3128"} {
+		if !strings.Contains(env, expected) {
+			t.Errorf("missing %s in the proxy env", expected)
+		}
+	}
+
+	if _, errs := Provider().Schema["all_proxy"].ValidateFunc("http://proxy:3128", "all_proxy"); len(errs) == 0 {
+		t.Errorf("expected the non-SOCKS all_proxy to fail")
+	}
+}
+
+// TestConfigureProviderProxyEnv tests that the proxy settings are passed to the Helm commands
+func TestConfigureProviderProxyEnv(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"helm_bin_path": fakeHelmBin(t, "v3.14.2"),
+		"cache_dir":     t.TempDir(),
+		"http_proxy":    "http://proxy:3128",
+		"https_proxy":   "http://proxy:3129",
+		"no_proxy":      "localhost,.svc",
+	})
+
+	m, diags := configureProvider(context.Background(), d)
+	if diags.HasError() {
+		t.Fatalf("configureProvider failed: %v", diags)
+	}
+
+	env := strings.Join(m.(*ProviderConfig).HelmCmd("version").Env, "\n")
+	for _, expected := range []string{"HTTP_PROXY=http://proxy:3128", "https_proxy=http://proxy:3129", "NO_PROXY=localhost,.svc"} {
+		if !strings.Contains(env, expected) {
+			t.Errorf("missing %s in the Helm command env", expected)
+		}
+	}
+}
+
+// TestConfigureProviderHelmEnv tests that the helm_env variables are passed to the Helm commands
+func TestConfigureProviderHelmEnv(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"helm_bin_path": fakeHelmBin(t, "v3.14.2"),
+		"cache_dir":     t.TempDir(),
+		"https_proxy":   "http://proxy:3129",
+		"kube_token":    "secret",
+		"helm_env": map[string]interface{}{
+			"HELM_REPOSITORY_CONFIG": "/tmp/repositories.yaml",
+			"HELM_PLUGINS":           "/tmp/plugins",
+			"HTTPS_PROXY":            "http://other:3128",
+		},
+	})
+
+	m, diags := configureProvider(context.Background(), d)
+	if diags.HasError() {
+		t.Fatalf("configureProvider failed: %v", diags)
+	}
+
+	helmCmd := m.(*ProviderConfig).HelmCmd("version")
+	env := strings.Join(helmCmd.Env, "\n")
+	for _, expected := range []string{"HELM_REPOSITORY_CONFIG=/tmp/repositories.yaml", "HELM_PLUGINS=/tmp/plugins"} {
+		if !strings.Contains(env, expected) {
+			t.Errorf("missing %s in the Helm command env", expected)
+		}
+	}
+	if last := helmCmd.Env[len(helmCmd.Env)-1]; !strings.HasPrefix(last, "https_proxy=") && !strings.HasPrefix(last, "HTTPS_PROXY=") {
+		t.Errorf("provider proxy env should take precedence over helm_env, got last: %s", last)
+	}
+	if !strings.Contains(strings.Join(helmCmd.Args, " "), "--kube-token secret") {
+		t.Errorf("missing kube auth args: %v", helmCmd.Args)
+	}
+}
+
+// TestConfigureProviderHelmQuiet tests that the quiet mode disables Helm colors and debug output unless 'helm_env' overrides them
+func TestConfigureProviderHelmQuiet(t *testing.T) {
+	for _, quiet := range []bool{true, false} {
+		d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+			"helm_bin_path": fakeHelmBin(t, "v3.14.2"),
+			"cache_dir":     t.TempDir(),
+			"helm_quiet":    quiet,
+			"helm_env":      map[string]interface{}{"TERM": "xterm"},
+		})
+
+		m, diags := configureProvider(context.Background(), d)
+		if diags.HasError() {
+			t.Fatalf("configureProvider failed: %v", diags)
+		}
+		if cfg := m.(*ProviderConfig); cfg.HelmQuiet != quiet {
+			t.Errorf("unexpected quiet mode: %v", cfg.HelmQuiet)
+		}
+
+		env := m.(*ProviderConfig).HelmCmd("version").Env
+		joined := strings.Join(env, "\n")
+		for _, expected := range []string{"HELM_DEBUG=false", "NO_COLOR=1"} {
+			if strings.Contains(joined, expected) != quiet {
+				t.Errorf("unexpected %s in the Helm command env with quiet %v", expected, quiet)
+			}
+		}
+		if quiet && strings.LastIndex(joined, "TERM=xterm") < strings.LastIndex(joined, "TERM=dumb") {
+			t.Errorf("helm_env should take precedence over the quiet env: %v", env)
+		}
+	}
+}
+
+// TestConfigureProviderKubeconfigPaths tests that the kubeconfig files are passed to Helm as the KUBECONFIG list
+func TestConfigureProviderKubeconfigPaths(t *testing.T) {
+	t.Setenv("KUBECONFIG", "/tmp/env-kubeconfig")
+
+	dir := t.TempDir()
+	var paths []interface{}
+	for _, name := range []string{"dev", "prod"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("current-context: "+name+"\n"), 0600); err != nil {
+			t.Fatalf("failed to write kubeconfig: %v", err)
+		}
+		paths = append(paths, path)
+	}
+
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"helm_bin_path":    fakeHelmBin(t, "v3.14.2"),
+		"cache_dir":        t.TempDir(),
+		"kubeconfig_paths": paths,
+	})
+
+	m, diags := configureProvider(context.Background(), d)
+	if diags.HasError() {
+		t.Fatalf("configureProvider failed: %v", diags)
+	}
+
+	helmCmd := m.(*ProviderConfig).HelmCmd("version")
+	expected := "KUBECONFIG=" + paths[0].(string) + string(os.PathListSeparator) + paths[1].(string)
+	if !strings.Contains(strings.Join(helmCmd.Env, "\n"), expected) {
+		t.Errorf("missing %s in the Helm command env", expected)
+	}
+	if containsArgs(helmCmd.Args, "--kubeconfig", "/tmp/env-kubeconfig") {
+		t.Errorf("KUBECONFIG default of 'kubeconfig' should be ignored: %v", helmCmd.Args)
+	}
+	if kubeContext := m.(*ProviderConfig).KubeAuth.contextName(); kubeContext != "dev" {
+		t.Errorf("unexpected kube context: %s", kubeContext)
+	}
+
+	d = schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"helm_bin_path":    fakeHelmBin(t, "v3.14.2"),
+		"cache_dir":        t.TempDir(),
+		"kubeconfig_paths": []interface{}{paths[0], filepath.Join(dir, "missing")},
+	})
+	if _, diags := configureProvider(context.Background(), d); !diags.HasError() || !strings.Contains(diags[0].Summary, "missing") {
+		t.Errorf("expected missing kubeconfig error, got: %v", diags)
+	}
+}
+
+// TestConfigureProviderHelmPaths tests that the Helm repository and registry paths default to the cache directory
+func TestConfigureProviderHelmPaths(t *testing.T) {
+	cacheDir := t.TempDir()
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"helm_bin_path":        fakeHelmBin(t, "v3.14.2"),
+		"cache_dir":            cacheDir,
+		"helm_registry_config": "/tmp/registry.json",
+	})
+
+	m, diags := configureProvider(context.Background(), d)
+	if diags.HasError() {
+		t.Fatalf("configureProvider failed: %v", diags)
+	}
+
+	helmPaths := m.(*ProviderConfig).HelmPaths
+	for _, path := range []string{helmPaths.RepositoryConfig, helmPaths.RepositoryCache} {
+		if !strings.HasPrefix(path, cacheDir) {
+			t.Errorf("unexpected default path outside of the cache directory: %s", path)
+		}
+	}
+
+	env := strings.Join(m.(*ProviderConfig).HelmCmd("version").Env, "\n")
+	for _, expected := range []string{
+		"HELM_REPOSITORY_CONFIG=" + filepath.Join(cacheDir, "config", "repositories.yaml"),
+		"HELM_REPOSITORY_CACHE=" + filepath.Join(cacheDir, "repository"),
+		"HELM_REGISTRY_CONFIG=/tmp/registry.json",
+	} {
+		if !strings.Contains(env, expected) {
+			t.Errorf("missing %s in the Helm command env", expected)
+		}
+	}
+}
+
+// TestConfigureProviderRepositoryCacheDir tests that the Helm repository cache is kept in the long-lived directory
+func TestConfigureProviderRepositoryCacheDir(t *testing.T) {
+	repositoryCacheDir := filepath.Join(t.TempDir(), "helm", "repository")
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"helm_bin_path":        fakeHelmBin(t, "v3.14.2"),
+		"cache_dir":            t.TempDir(),
+		"repository_cache_dir": repositoryCacheDir,
+	})
+
+	m, diags := configureProvider(context.Background(), d)
+	if diags.HasError() {
+		t.Fatalf("configureProvider failed: %v", diags)
+	}
+
+	if info, err := os.Stat(repositoryCacheDir); err != nil || !info.IsDir() {
+		t.Errorf("expected the repository cache directory to be created: %v", err)
+	}
+	env := strings.Join(m.(*ProviderConfig).HelmCmd("version").Env, "\n")
+	if !strings.Contains(env, "HELM_REPOSITORY_CACHE="+repositoryCacheDir+"\n") {
+		t.Errorf("expected HELM_REPOSITORY_CACHE to point at the repository cache directory, got:\n%s", env)
+	}
+}
+
+// TestNewHTTPClientCABundle tests that the configured CA bundle is trusted by the HTTP client
+func TestNewHTTPClientCABundle(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	caBundleFile := filepath.Join(t.TempDir(), "ca.pem")
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	if err := os.WriteFile(caBundleFile, caBundle, 0600); err != nil {
+		t.Fatalf("failed to write CA bundle: %v", err)
+	}
+
+	destPath := filepath.Join(t.TempDir(), "file")
+
+	client, err := newHTTPClient(ProxyConfig{}, "")
+	if err != nil {
+		t.Fatalf("newHTTPClient failed: %v", err)
+	}
+	if err := downloadFile(client, ts.URL, destPath); err == nil {
+		t.Errorf("expected download from the untrusted server to fail")
+	}
+
+	client, err = newHTTPClient(ProxyConfig{}, caBundleFile)
+	if err != nil {
+		t.Fatalf("newHTTPClient failed: %v", err)
+	}
+	if err := downloadFile(client, ts.URL, destPath); err != nil {
+		t.Errorf("downloadFile failed with the configured CA bundle: %v", err)
+	}
+
+	if _, err := newHTTPClient(ProxyConfig{}, destPath); err == nil {
+		t.Errorf("expected invalid CA bundle to fail")
+	}
+}
+
+// TestConfigureProviderCABundle tests that the inline CA bundle is stored in the cache directory
+func TestConfigureProviderCABundle(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	cacheDir := t.TempDir()
+	caBundle := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}))
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"helm_bin_path": fakeHelmBin(t, "v3.14.2"),
+		"cache_dir":     cacheDir,
+		"ca_bundle":     caBundle,
+	})
+
+	m, diags := configureProvider(context.Background(), d)
+	if diags.HasError() {
+		t.Fatalf("configureProvider failed: %v", diags)
+	}
+
+	caBundleFile := m.(*ProviderConfig).CABundleFile
+	if !strings.HasPrefix(caBundleFile, cacheDir) {
+		t.Errorf("unexpected CA bundle file location: %s", caBundleFile)
+	}
+	if content, _ := os.ReadFile(caBundleFile); string(content) != caBundle {
+		t.Errorf("unexpected CA bundle file content: %s", content)
+	}
+}
+
+// TestConfigureProviderCacheHashLength tests that 'cache_hash_length' names the cached files
+func TestConfigureProviderCacheHashLength(t *testing.T) {
+	defer func(length int) { cacheHashLength = length }(cacheHashLength)
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	cacheDir := t.TempDir()
+	caBundle := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}))
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"helm_bin_path":     fakeHelmBin(t, "v3.14.2"),
+		"cache_dir":         cacheDir,
+		"ca_bundle":         caBundle,
+		"cache_hash_length": 24,
+	})
+	m, diags := configureProvider(context.Background(), d)
+	if diags.HasError() {
+		t.Fatalf("configureProvider failed: %v", diags)
+	}
+	if hash := generateHash("input"); len(hash) != 24 {
+		t.Errorf("unexpected hash length: %s", hash)
+	}
+	if name := filepath.Base(m.(*ProviderConfig).CABundleFile); name != generateHash(caBundle)+".pem" {
+		t.Errorf("unexpected CA bundle file name: %s", name)
+	}
+
+	// The default length is used if it isn't set
+	d = schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"helm_bin_path": fakeHelmBin(t, "v3.14.2"),
+		"cache_dir":     cacheDir,
+	})
+	if _, diags := configureProvider(context.Background(), d); diags.HasError() {
+		t.Fatalf("configureProvider failed: %v", diags)
+	}
+	if hash := generateHash("input"); len(hash) != 16 {
+		t.Errorf("unexpected default hash length: %s", hash)
+	}
+
+	for _, length := range []int{4, 40} {
+		if _, errs := Provider().Schema["cache_hash_length"].ValidateFunc(length, "cache_hash_length"); len(errs) == 0 {
+			t.Errorf("expected invalid cache_hash_length to fail: %d", length)
+		}
+	}
+}
+
+// TestCleanCache tests that only the expired cache entries are removed
+func TestCleanCache(t *testing.T) {
+	cacheDir := t.TempDir()
+	helmDir := filepath.Join(cacheDir, "helm", "v3.14.2")
+
+	tests := []struct {
+		path    string
+		expired bool
+		kept    bool
+	}{
+		{filepath.Join(cacheDir, "helm", "v3.9.4"), true, false},
+		{helmDir, true, true},
+		{filepath.Join(cacheDir, "repos", "nginx-abc"), true, false},
+		{filepath.Join(cacheDir, "repos", "nginx-def"), false, true},
+		{filepath.Join(cacheDir, "values", "nginx"), false, true},
+		{filepath.Join(cacheDir, "postrender", "abc"), true, false},
+		{filepath.Join(cacheDir, "config"), true, true},
+	}
+
+	old := time.Now().Add(-48 * time.Hour)
+	for _, tt := range tests {
+		if err := os.MkdirAll(tt.path, os.ModePerm); err != nil {
+			t.Fatalf("failed to create %s: %v", tt.path, err)
+		}
+		if tt.expired {
+			if err := os.Chtimes(tt.path, old, old); err != nil {
+				t.Fatalf("failed to change times of %s: %v", tt.path, err)
+			}
+		}
+	}
+
+	removed, err := cleanCache(cacheDir, 24*time.Hour, helmDir)
+	if err != nil {
+		t.Fatalf("cleanCache failed: %v", err)
+	}
+	if len(removed) != 3 {
+		t.Errorf("unexpected removed entries: %v", removed)
+	}
+
+	for _, tt := range tests {
+		if _, err := os.Stat(tt.path); (err == nil) != tt.kept {
+			t.Errorf("unexpected state of %s, expected kept: %v", tt.path, tt.kept)
+		}
+	}
+}
+
+// roundTripFunc serves the HTTP requests by the function
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// TestInstallHelmCLIPlatform tests that the cached Helm binary is per platform and the other platform binary isn't reused
+func TestInstallHelmCLIPlatform(t *testing.T) {
+	cacheDir := t.TempDir()
+
+	helmBinPath := helmCachePath(cacheDir, "v3.14.2", "linux", "arm64")
+	if expected := filepath.Join(cacheDir, "helm", "v3.14.2", "linux_arm64", "helm"); helmBinPath != expected {
+		t.Errorf("unexpected Helm binary path: %s, expected: %s", helmBinPath, expected)
+	}
+
+	otherArch := "amd64"
+	if runtime.GOARCH == "amd64" {
+		otherArch = "arm64"
+	}
+	otherBinPath := helmCachePath(cacheDir, "v3.14.2", runtime.GOOS, otherArch)
+	if err := os.MkdirAll(filepath.Dir(otherBinPath), os.ModePerm); err != nil {
+		t.Fatalf("failed to create the cache directory: %v", err)
+	}
+	if err := os.WriteFile(otherBinPath, []byte("#!/bin/sh\necho other\n"), 0700); err != nil {
+		t.Fatalf("failed to create the other platform Helm binary: %v", err)
+	}
+
+	var downloads int
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		downloads++
+		script := "#!/bin/sh\nprintf '#!/bin/sh\\necho v3.14.2\\n' > \"$HELM_INSTALL_DIR/helm\"\nchmod 700 \"$HELM_INSTALL_DIR/helm\"\n"
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(script)),
+			Request:    req,
+		}, nil
+	})}
+
+	for i := 0; i < 2; i++ {
+		installedPath, err := installHelmCLI(client, nil, "v3.14.2", cacheDir)
+		if err != nil {
+			t.Fatalf("installHelmCLI failed: %v", err)
+		}
+		if installedPath != helmCachePath(cacheDir, "v3.14.2", runtime.GOOS, runtime.GOARCH) {
+			t.Errorf("unexpected Helm binary path: %s", installedPath)
+		}
+	}
+	if downloads != 1 {
+		t.Errorf("expected the Helm binary to be installed once, got %d downloads", downloads)
+	}
+
+	if content, _ := os.ReadFile(otherBinPath); !strings.Contains(string(content), "other") {
+		t.Errorf("the other platform Helm binary is changed: %s", content)
+	}
+}
+
+// TestInstallHelmCLINestedBinary tests that the Helm binary nested by the installer into the '<os>-<arch>' subdirectory is found
+func TestInstallHelmCLINestedBinary(t *testing.T) {
+	cacheDir := t.TempDir()
+
+	var downloads int
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		downloads++
+		script := "#!/bin/sh\nmkdir -p \"$HELM_INSTALL_DIR/linux-amd64\"\nprintf '#!/bin/sh\\necho v3.14.2\\n' > \"$HELM_INSTALL_DIR/linux-amd64/helm\"\nchmod 700 \"$HELM_INSTALL_DIR/linux-amd64/helm\"\n"
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(script)),
+			Request:    req,
+		}, nil
+	})}
+
+	expected := filepath.Join(filepath.Dir(helmCachePath(cacheDir, "v3.14.2", runtime.GOOS, runtime.GOARCH)), "linux-amd64", "helm")
+	for i := 0; i < 2; i++ {
+		installedPath, err := installHelmCLI(client, nil, "v3.14.2", cacheDir)
+		if err != nil {
+			t.Fatalf("installHelmCLI failed: %v", err)
+		}
+		if installedPath != expected {
+			t.Errorf("unexpected Helm binary path: %s, expected: %s", installedPath, expected)
+		}
+	}
+	if downloads != 1 {
+		t.Errorf("expected the nested Helm binary to be cached, got %d downloads", downloads)
+	}
+
+	if _, err := findHelmBinary(t.TempDir()); err == nil {
+		t.Errorf("expected the missing Helm binary to fail")
+	}
+}
+
+// TestLockRelease tests that the operations on the same release are serialized while the other releases stay concurrent
+func TestLockRelease(t *testing.T) {
+	cfg := &ProviderConfig{}
+
+	var mu sync.Mutex
+	active, maxActive := 0, 0
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := cfg.lockRelease("test-namespace", "test-helm-release")
+			defer unlock()
+
+			mu.Lock()
+			active++
+			if active > maxActive {
+				maxActive = active
+			}
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			active--
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if maxActive != 1 {
+		t.Errorf("operations on the same release weren't serialized, max concurrent: %d", maxActive)
+	}
+
+	unlock := cfg.lockRelease("test-namespace", "test-helm-release")
+	defer unlock()
+
+	done := make(chan struct{})
+	go func() {
+		cfg.lockRelease("other-namespace", "test-helm-release")()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("operation on the other release was blocked")
+	}
+}
+
+// TestLogHelmOperation tests that the structured Helm operation event is logged only with the JSON log format
+func TestLogHelmOperation(t *testing.T) {
+	for _, logFormat := range []string{"json", "text"} {
+		var output bytes.Buffer
+		ctx := tflogtest.RootLogger(context.Background(), &output)
+
+		cfg := &ProviderConfig{LogFormat: logFormat}
+		cmd := exec.Command("sh", "-c", "exit 3", "--kube-token", "secret")
+		err := cmd.Run()
+		cfg.logHelmOperation(ctx, "test-namespace", "test-helm-release", cmd, 1500*time.Millisecond, err)
+
+		entries, err := tflogtest.MultilineJSONDecode(&output)
+		if err != nil {
+			t.Fatalf("failed to decode logs: %v", err)
+		}
+		if logFormat == "text" {
+			if len(entries) != 0 {
+				t.Errorf("unexpected logs for the text format: %v", entries)
+			}
+			continue
+		}
+
+		if len(entries) != 1 {
+			t.Fatalf("unexpected logs: %v", entries)
+		}
+		expected := map[string]interface{}{
+			"@message":          "Helm operation finished",
+			"helm_command":      "sh -c exit 3 --kube-token ***",
+			"duration_seconds":  1.5,
+			"exit_code":         float64(3),
+			"release_name":      "test-helm-release",
+			"release_namespace": "test-namespace",
+		}
+		for key, value := range expected {
+			if entries[0][key] != value {
+				t.Errorf("unexpected %s: %v", key, entries[0][key])
+			}
+		}
+	}
+}
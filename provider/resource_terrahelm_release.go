@@ -3,46 +3,128 @@ package provider
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"crypto/md5"
+	"crypto/sha256"
 
 	"github.com/hashicorp/go-getter"
+	"github.com/hashicorp/go-version"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"gopkg.in/yaml.v3"
 )
 
+// Helm release naming rules, see: https://github.com/helm/helm/blob/main/pkg/chartutil/validate_name.go
+const releaseNameMaxLen = 53
+
+// Length of the hashes naming the cached files, set by the provider 'cache_hash_length'. The short ones collide for the different inputs, so one cached file overwrites another
+var cacheHashLength = 16
+
+// Length of the hashes naming the cache of the earlier provider versions
+const legacyCacheHashLength = 8
+
+// Helm version constraints required by the optional features
+const (
+	takeOwnershipHelmVersion = ">= 3.17.0"
+	getMetadataHelmVersion   = ">= 3.13.0"
+	setLiteralHelmVersion    = ">= 3.10.0"
+)
+
+// Polling settings of the 'wait_for' resources, Helm default timeout is used if 'timeout' isn't set
+var (
+	waitForPollInterval   = 5 * time.Second
+	waitForDefaultTimeout = 5 * time.Minute
+)
+
+// Polling interval of the release status until it's deployed within 'readiness_timeout'
+var readinessPollInterval = 5 * time.Second
+
+// Retry settings of the Helm command failed since the namespace is still being created by another resource
+var (
+	namespaceRetryAttempts = 3
+	namespaceRetryDelay    = 5 * time.Second
+)
+
+// Retry settings of the Helm command failed since another operation on the release is in progress,
+// the release status is polled with the exponential backoff starting from the delay until it isn't pending
+var (
+	operationRetryAttempts = 3
+	operationPollAttempts  = 6
+	operationRetryDelay    = 5 * time.Second
+	operationRetryMaxDelay = time.Minute
+)
+
+// sensitiveArgs are the command flags which values must not be logged
+var sensitiveArgs = map[string]bool{
+	"--password":   true,
+	"--kube-token": true,
+}
+
+// sensitiveURLParams are the go-getter URL parameters which values must not be stored
+var sensitiveURLParams = map[string]bool{
+	"sshkey":                true,
+	"aws_access_key_secret": true,
+	"aws_access_token":      true,
+	"sig":                   true,
+}
+
+var releaseNameRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+// releaseNameAffixRegexp matches the provider release name prefix and suffix, they may start or end with the separator
+var releaseNameAffixRegexp = regexp.MustCompile(`^[-.a-z0-9]+$`)
+
+// ansiRegexp matches the ANSI escape sequences of the colorized command output
+var ansiRegexp = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+
+// chartShorthandRegexp matches Helm CLI chart reference of the added repository, e.g. 'bitnami/nginx'
+var chartShorthandRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*/[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
 func resourceHelmRelease() *schema.Resource {
 	return &schema.Resource{
 		Description: "Helm chart release deployment",
-		CreateContext: func(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+		CreateContext: withReleaseLock(withHelmHome(withCommandTimeout(func(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 			return resourceHelmReleaseCreateOrUpdate(ctx, d, m, false)
-		},
-		UpdateContext: func(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+		}))),
+		UpdateContext: withReleaseLock(withHelmHome(withCommandTimeout(func(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 			return resourceHelmReleaseCreateOrUpdate(ctx, d, m, true)
-		},
-		ReadContext:   resourceHelmReleaseRead,
-		DeleteContext: resourceHelmReleaseDelete,
+		}))),
+		ReadContext:   withReleaseLock(withHelmHome(withCommandTimeout(resourceHelmReleaseRead))),
+		DeleteContext: withReleaseLock(withHelmHome(withCommandTimeout(resourceHelmReleaseDelete))),
 		Schema: map[string]*schema.Schema{
 			"name": {
-				Description: "Name of the Helm release",
+				Description: "Name of the Helm release, the provider 'release_name_prefix' and 'release_name_suffix' are added to it",
 				Type:        schema.TypeString,
 				Required:    true,
 				ForceNew:    true,
+				ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+					if err := validateReleaseName(val.(string)); err != nil {
+						errs = append(errs, fmt.Errorf("%q: %s", key, err))
+					}
+					return
+				},
 			},
 			"chart_repository": {
-				Description: "URL of the chart repository containing the Helm chart, Helm cli is used for downloading",
+				Description: "URL of the chart repository containing the Helm chart, Helm cli is used for downloading. The OCI registry ('oci://') is logged in with the repository credentials for the operation only",
 				Type:        schema.TypeString,
 				Optional:    true,
 				ForceNew:    true,
@@ -54,10 +136,22 @@ func resourceHelmRelease() *schema.Resource {
 				ForceNew:    true,
 			},
 			"chart_url": {
-				Description: "URL to the Helm chart, it supports advanced parameters, archives and variety of protocols: http::, file::, s3::, gcs::, hg::",
+				Description: "URL to the Helm chart, it supports advanced parameters, archives and variety of protocols: http::, file::, s3::, gcs::, hg::. The 'repo/chart' shorthand of the added Helm repository is installed from the repository",
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"chart_local_path": {
+				Description: "Path to the Helm chart directory or the packaged chart on the local filesystem, it's used as is without downloading",
 				Type:        schema.TypeString,
 				Optional:    true,
 				ForceNew:    true,
+				ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+					if _, err := os.Stat(val.(string)); err != nil {
+						errs = append(errs, fmt.Errorf("%q: chart path '%s' is not found", key, val))
+					}
+					return
+				},
 			},
 			"git_reference": {
 				Description: "Reference (e.g. branch, tag, commit hash) to checkout in the Git repository",
@@ -69,8 +163,44 @@ func resourceHelmRelease() *schema.Resource {
 				Type:        schema.TypeString,
 				Optional:    true,
 			},
+			"git_submodules": {
+				Description: "Initialize the Git submodules of the cloned repository recursively, the 'git_repository' credentials are used for the submodules on the same host",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"git_lfs": {
+				Description: "Fetch the Git LFS files of the cloned repository, e.g. the large chart assets, requires the 'git-lfs' binary. It's skipped if the repository doesn't use Git LFS",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"git_clone_depth": {
+				Description: "Depth of the Git repository clone history, 0 fetches the full history, e.g. to resolve the annotated tag",
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     1,
+				ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+					if v := val.(int); v < 0 {
+						errs = append(errs, fmt.Errorf("%q: must be 0 or greater, got: %d", key, v))
+					}
+					return
+				},
+			},
+			"git_single_branch": {
+				Description: "Clone only the history of the 'git_reference' branch or tag, disable it to fetch the other branches too",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+			},
+			"git_sparse_checkout": {
+				Description: "Fetch only the 'chart_path' and the relative values files directories of the Git repository using sparse checkout, the full clone is used if it isn't supported",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
 			"insecure": {
-				Description: "Disable checking certificates (not safe)",
+				Description: "Disable checking certificates (not safe), including the OCI registry ones, e.g. of the self-signed registry. The chart and the registry credentials are exposed to the man-in-the-middle attacks then",
 				Type:        schema.TypeBool,
 				Optional:    true,
 				Default:     false,
@@ -88,6 +218,28 @@ func resourceHelmRelease() *schema.Resource {
 				Optional:    true,
 				Default:     false,
 			},
+			"require_namespace": {
+				Description: "Whether to check that the Kubernetes namespace exists before the installation, can't be used with 'create_namespace'",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"namespace_labels": {
+				Description: "Labels to set on the Kubernetes namespace, requires 'create_namespace'",
+				Type:        schema.TypeMap,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+				Optional: true,
+			},
+			"namespace_annotations": {
+				Description: "Annotations to set on the Kubernetes namespace, requires 'create_namespace'",
+				Type:        schema.TypeMap,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+				Optional: true,
+			},
 			"values": {
 				Description: "A YAML string representing the values to be passed to the Helm chart",
 				Type:        schema.TypeString,
@@ -97,6 +249,20 @@ func resourceHelmRelease() *schema.Resource {
 					return safeVal
 				},
 			},
+			"ignore_values": {
+				Description: "Dotted key paths of the values to ignore on the drift detection, e.g. 'podAnnotations.checksum' mutated in the cluster. The dots of the key are escaped with '\\'",
+				Type:        schema.TypeList,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+					ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+						if _, err := splitValuesPath(val.(string)); err != nil {
+							errs = append(errs, fmt.Errorf("%q: %s", key, err))
+						}
+						return
+					},
+				},
+				Optional: true,
+			},
 			"values_files": {
 				Description: "A list of the values file names or URLs to be passed to the Helm chart",
 				Type:        schema.TypeList,
@@ -105,8 +271,133 @@ func resourceHelmRelease() *schema.Resource {
 				},
 				Optional: true,
 			},
+			"values_files_username": {
+				Description: "Username for the HTTP basic authentication of the values files URLs. S3, GCS and Azure Blob Storage credentials are passed by the URL parameters or the environment, see README",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"values_files_password": {
+				Description: "Password for the HTTP basic authentication of the values files URLs",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+			},
+			"values_files_headers": {
+				Description: "HTTP headers sent with the values files URLs requests, e.g. 'Authorization' with the bearer token",
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Sensitive:   true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"values_from": {
+				Description: "ConfigMaps or Secrets keys to fetch from the cluster and pass to the Helm chart as the values files after 'values_files', the inline 'values' take precedence over them",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"kind": {
+							Description: "Kind of the values source: 'ConfigMap' or 'Secret'",
+							Type:        schema.TypeString,
+							Required:    true,
+							ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+								if kind := val.(string); kind != "ConfigMap" && kind != "Secret" {
+									errs = append(errs, fmt.Errorf("%q: must be 'ConfigMap' or 'Secret', got: %s", key, kind))
+								}
+								return
+							},
+						},
+						"name": {
+							Description: "Name of the ConfigMap or Secret",
+							Type:        schema.TypeString,
+							Required:    true,
+						},
+						"namespace": {
+							Description: "Namespace of the ConfigMap or Secret, defaults to the release namespace",
+							Type:        schema.TypeString,
+							Optional:    true,
+						},
+						"key": {
+							Description: "Key of the ConfigMap or Secret holding the values YAML",
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "values.yaml",
+						},
+					},
+				},
+			},
+			"set_literal": {
+				Description: "Values to pass to the Helm chart with '--set-literal' as the literal strings, the commas, dots and brackets of the value aren't parsed unlike '--set-string'. They take precedence over the other values, requires Helm >= 3.10.0",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Description: "Name of the value, the dots separate the nested keys, e.g. 'podAnnotations.allowed-cidrs'",
+							Type:        schema.TypeString,
+							Required:    true,
+						},
+						"value": {
+							Description: "Literal string value",
+							Type:        schema.TypeString,
+							Required:    true,
+						},
+					},
+				},
+			},
 			"chart_version": {
-				Description: "The version of the Helm chart to install",
+				Description: "The version of the Helm chart to install, can be used only with 'chart_repository' or the 'repo/chart' shorthand 'chart_url'",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"pin_resolved_version": {
+				Description: "Pin the chart version resolved on install when 'chart_version' is empty or a constraint, so the later upgrades don't pull a newer chart. Change 'chart_version' to resolve the version again. Can be used only with 'chart_repository' or the 'repo/chart' shorthand 'chart_url'",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"resolved_chart_version": {
+				Description: "The chart version pinned by 'pin_resolved_version'",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"repository_username": {
+				Description: "Username for the chart repository authentication, the provider 'repository_username' and 'repository_password' are used if neither of them is set",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"repository_password": {
+				Description: "Password for the chart repository authentication",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+			},
+			"repository_ca_file": {
+				Description: "Path to the CA file verifying the chart repository certificate, the provider 'repository_ca_file' and 'ca_bundle_file' are used if it's not set",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"pass_credentials": {
+				Description: "Pass the repository credentials to all domains, e.g. when the chart repository redirects to a CDN. Only enable it for trusted repositories, since the credentials are sent to any host the repository redirects to",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"verify": {
+				Description: "Verify the chart provenance before installing it, requires a packaged chart with the '.prov' file",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"validate_values_schema": {
+				Description: "Validate the merged values against the chart 'values.schema.json' before installing it, requires a local or packaged chart",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"keyring": {
+				Description: "Location of the public keys used for the chart verification",
 				Type:        schema.TypeString,
 				Optional:    true,
 			},
@@ -115,18 +406,30 @@ func resourceHelmRelease() *schema.Resource {
 				Type:        schema.TypeBool,
 				Optional:    true,
 				Default:     false,
-				DiffSuppressFunc: func(k, oldValue, newValue string, d *schema.ResourceData) bool {
-					return true
-				},
+			},
+			"wait_on_install": {
+				Description: "Whether to wait for the Helm chart to be ready on install only, 'wait' enables it for all the operations",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"wait_on_upgrade": {
+				Description: "Whether to wait for the Helm chart to be ready on upgrade only, 'wait' enables it for all the operations",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
 			},
 			"atomic": {
 				Description: "Whether to roll back the Helm chart installation if it fails",
 				Type:        schema.TypeBool,
 				Optional:    true,
 				Default:     true,
-				DiffSuppressFunc: func(k, oldValue, newValue string, d *schema.ResourceData) bool {
-					return true
-				},
+			},
+			"atomic_on_install": {
+				Description: "Whether to use 'atomic' on the first install too, disable it to keep the failed initial release for the inspection",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
 			},
 			"debug": {
 				Description: "Enable debug mode for the Helm CLI",
@@ -134,6 +437,83 @@ func resourceHelmRelease() *schema.Resource {
 				Optional:    true,
 				Default:     false,
 			},
+			"dependency_timeout": {
+				Description: "The maximum run time of 'helm dependency build', it overrides the provider 'command_timeout' for the slow dependency repositories. Helm duration format or seconds are accepted",
+				Type:        schema.TypeString,
+				Optional:    true,
+				ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+					if timeout, err := time.ParseDuration(normalizeTimeout(val.(string))); err != nil || timeout <= 0 {
+						errs = append(errs, fmt.Errorf("%q: must be a positive duration, e.g. '5m', got: %s", key, val))
+					}
+					return
+				},
+			},
+			"dependency_update_on_install": {
+				Description: "Resolve the chart dependencies by Helm install or upgrade with '--dependency-update' instead of the separate 'helm dependency build' of the downloaded chart",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"dependency_repositories": {
+				Description: "Chart repositories of the chart dependencies to add before 'helm dependency build', e.g. the private ones requiring the authentication",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"url": {
+							Description: "URL of the dependency chart repository, it must match the 'repository' of the dependency in Chart.yaml",
+							Type:        schema.TypeString,
+							Required:    true,
+						},
+						"username": {
+							Description: "Username for the dependency chart repository authentication",
+							Type:        schema.TypeString,
+							Optional:    true,
+						},
+						"password": {
+							Description: "Password for the dependency chart repository authentication",
+							Type:        schema.TypeString,
+							Optional:    true,
+							Sensitive:   true,
+						},
+					},
+				},
+			},
+			"wait_for": {
+				Description: "Kubernetes resources of the release to poll after the installation until the condition is true, e.g. the custom resources Helm doesn't track with 'wait'. The apply fails if they aren't ready within 'timeout'",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"kind": {
+							Description: "Kind of the resource",
+							Type:        schema.TypeString,
+							Required:    true,
+						},
+						"name": {
+							Description: "Name of the resource",
+							Type:        schema.TypeString,
+							Required:    true,
+						},
+						"namespace": {
+							Description: "Namespace of the resource, defaults to the release namespace",
+							Type:        schema.TypeString,
+							Optional:    true,
+						},
+						"api_version": {
+							Description: "API version of the resource, it's detected from the release manifest if not set",
+							Type:        schema.TypeString,
+							Optional:    true,
+						},
+						"condition": {
+							Description: "Type of the status condition which must be true",
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "Ready",
+						},
+					},
+				},
+			},
 			"timeout": {
 				Description: "The maximum time to wait for the Helm chart installation to complete",
 				Type:        schema.TypeString,
@@ -142,16 +522,57 @@ func resourceHelmRelease() *schema.Resource {
 					return true
 				},
 			},
+			"readiness_timeout": {
+				Description: "The maximum time for the Helm release to reach the 'deployed' status after the install or upgrade, the apply fails otherwise even without 'atomic'. Helm duration format or seconds are accepted",
+				Type:        schema.TypeString,
+				Optional:    true,
+				ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+					if _, err := time.ParseDuration(normalizeTimeout(val.(string))); err != nil {
+						errs = append(errs, fmt.Errorf("%q: invalid duration: %s", key, err))
+					}
+					return
+				},
+			},
 			"custom_args": {
-				Description: "Additional arguments to pass to the Helm CLI",
+				Description: "Additional arguments to pass to the Helm CLI, the release is stored from the JSON output unless the `--output` argument is set",
+				Type:        schema.TypeList,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+				Optional: true,
+			},
+			"install_args": {
+				Description: "Additional arguments to pass to the Helm CLI on install only, after 'custom_args'",
+				Type:        schema.TypeList,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+				Optional: true,
+			},
+			"upgrade_args": {
+				Description: "Additional arguments to pass to the Helm CLI on upgrade only, after 'custom_args'",
+				Type:        schema.TypeList,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+				Optional: true,
+			},
+			"post_install_command": {
+				Description: "Command and its arguments to run after the successful install or upgrade, e.g. the smoke tests or the notification. The release is passed by the TH_RELEASE_NAME, TH_RELEASE_NAMESPACE and TH_RELEASE_REVISION environment variables, the run time is limited by the provider 'command_timeout'",
 				Type:        schema.TypeList,
 				Elem: &schema.Schema{
 					Type: schema.TypeString,
 				},
 				Optional: true,
 			},
+			"post_install_fail_on_error": {
+				Description: "Whether to fail the apply if 'post_install_command' exits with the non-zero code, the failure is reported as the warning otherwise. The failed resource is tainted and replaced on the next apply",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+			},
 			"post_renderer": {
-				Description: "Post-renderer command to run",
+				Description: "Post-renderer command to run, the relative path is resolved in the chart source directory of the Git repository or the chart URL, the local chart directory or the release cache directory",
 				Type:        schema.TypeString,
 				Optional:    true,
 			},
@@ -161,9 +582,83 @@ func resourceHelmRelease() *schema.Resource {
 				Optional:    true,
 			},
 
+			"replace": {
+				Description: "Reuse the release name on install even if a release with this name is in a deleted or failed state",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"take_ownership": {
+				Description: "Adopt the existing Kubernetes resources into the release instead of failing on conflicts, requires Helm " + takeOwnershipHelmVersion,
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"helm_config_home": {
+				Description: "Directory to keep the Helm config, cache and data of the release in, e.g. to isolate the conflicting repositories configs. Sets HELM_CONFIG_HOME, HELM_CACHE_HOME and HELM_DATA_HOME and overrides the provider Helm repository and registry paths",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"recreate_triggers": {
+				Description: "Arbitrary map of values which change forces the Helm release upgrade even if the other arguments are unchanged",
+				Type:        schema.TypeMap,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+				Optional: true,
+			},
+			"rollback_to": {
+				Description: "Revision to roll back the Helm release to, rollback is performed instead of upgrade when it's changed. The configured values should match the revision ones to avoid an upgrade on the next apply",
+				Type:        schema.TypeInt,
+				Optional:    true,
+			},
+			"keep_history": {
+				Description: "Keep the release history on uninstall, the release name can be reused with 'replace' then",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"uninstall_description": {
+				Description: "Description recorded in the release history on uninstall for the audit trail, requires 'keep_history'",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"delete_dry_run": {
+				Description: "Simulate the uninstall with 'helm uninstall --dry-run' on destroy and log the resources that would be removed. The Helm release is kept in the cluster while Terraform removes it from the state, so it should be imported or adopted with 'replace' to be managed again",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"delete_args": {
+				Description: "Additional arguments to pass to 'helm uninstall' on destroy, e.g. '--cascade=foreground'. The flags of 'namespace', 'keep_history', 'uninstall_description' and 'delete_dry_run' aren't allowed",
+				Type:        schema.TypeList,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+					ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+						if field, ok := deleteArgField(val.(string)); ok {
+							errs = append(errs, fmt.Errorf("%q: '%s' conflicts with '%s', set it instead", key, val, field))
+						}
+						return
+					},
+				},
+				Optional: true,
+			},
+			"recreate_on_failed": {
+				Description: "Uninstall and install the Helm release instead of upgrading it when it's in the failed state. The release history is removed, so the failed install isn't rolled back even with 'rollback_on_failure'",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"rollback_on_failure": {
+				Description: "Whether to roll back the Helm release to the last deployed revision if the upgrade fails, the upgrade with 'atomic' is already rolled back by Helm",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+
 			// Computed values for storing additional info in the state
 			"release_revision": {
-				Description: "The revision of the installed Helm release",
+				Description: "The revision of the Helm release applied by Terraform, the latest revision is read if it is removed from the history",
 				Type:        schema.TypeString,
 				Computed:    true,
 			},
@@ -185,240 +680,692 @@ func resourceHelmRelease() *schema.Resource {
 					Type: schema.TypeString,
 				},
 			},
+			"release_values_json": {
+				Description: "The values passed to the Helm chart at installation time as JSON keeping the value types, use 'jsondecode' to access them",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
 			"release_status": {
 				Description: "The current status of the installed Helm release",
 				Type:        schema.TypeString,
 				Computed:    true,
 			},
-		},
-
-		CustomizeDiff: func(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
-			_, gitRepoOk := d.GetOk("git_repository")
-			_, helmRepoOk := d.GetOk("chart_repository")
-			_, chartUrlOk := d.GetOk("chart_url")
-			_, gitRefOk := d.GetOk("git_reference")
-
-			numFieldsSet := 0
-			if gitRepoOk {
-				numFieldsSet++
-			}
-			if helmRepoOk {
-				numFieldsSet++
-			}
-			if chartUrlOk {
-				numFieldsSet++
-			}
-
-			if numFieldsSet == 0 {
-				return fmt.Errorf("either 'git_repository', 'chart_repository', 'chart_url' must be set")
-			}
-			if numFieldsSet != 1 {
-				return fmt.Errorf("only one of 'git_repository', 'chart_repository', or 'chart_url' can be set")
-			}
-			if gitRefOk && !gitRepoOk {
-				return fmt.Errorf("'git_reference' can be used only with 'git_repository'")
-			}
-			return nil
-		},
+			"release_description": {
+				Description: "The description of the Helm release operation applied by Terraform, e.g. 'Upgrade complete'",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"release_namespace": {
+				Description: "The namespace of the installed Helm release",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"release_updated": {
+				Description: "The time of the last deployment of the installed Helm release in RFC 3339 format",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"release_app_version": {
+				Description: "The app version of the installed Helm chart",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"planned_changes": {
+				Description: "Summary of the Kubernetes object changes of the planned upgrade: '+' added, '-' removed and '~' changed objects, requires the provider 'plan_diff'",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"applied_values_files": {
+				Description: "The values files used by the last Helm install or upgrade in the applied order: the relative path in the chart source or the URL with the credentials redacted",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"last_operation_duration": {
+				Description: "Duration of the last successful Helm install or upgrade in seconds",
+				Type:        schema.TypeFloat,
+				Computed:    true,
+			},
+			"chart_metadata": {
+				Description: "The metadata from Chart.yaml of the deployed Helm chart",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Description: "The name of the chart",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"version": {
+							Description: "The version of the chart",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"app_version": {
+							Description: "The version of the app the chart contains",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"description": {
+							Description: "The description of the chart",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"type": {
+							Description: "The type of the chart: application or library",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+					},
+				},
+			},
+			"release_hooks": releaseHooksSchema(),
+			"release_notes": {
+				Description: "The rendered notes (NOTES.txt) of the installed Helm release",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"detect_manifest_drift": {
+				Description: "Compare the Helm release manifest with the one applied by Terraform on refresh and plan the upgrade if it's changed, e.g. by the manual 'helm upgrade'",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"config_hash": {
+				Description: "SHA-256 hash of the canonicalized Helm install or upgrade inputs applied by Terraform: the chart source and version, the values and the Helm flags. The credentials aren't included",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"manifest_hash": {
+				Description: "SHA-256 hash of the Helm release manifest applied by Terraform, it's stored with 'detect_manifest_drift'",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"manifest_drifted": {
+				Description: "Whether the Helm release manifest differs from the one applied by Terraform, it's detected with 'detect_manifest_drift'",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+		},
+
+		CustomizeDiff: func(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+			_, gitRepoOk := d.GetOk("git_repository")
+			_, helmRepoOk := d.GetOk("chart_repository")
+			_, chartUrlOk := d.GetOk("chart_url")
+			_, chartLocalPathOk := d.GetOk("chart_local_path")
+			_, gitRefOk := d.GetOk("git_reference")
+
+			numFieldsSet := 0
+			if gitRepoOk {
+				numFieldsSet++
+			}
+			if helmRepoOk {
+				numFieldsSet++
+			}
+			if chartUrlOk {
+				numFieldsSet++
+			}
+			if chartLocalPathOk {
+				numFieldsSet++
+			}
+
+			if numFieldsSet == 0 {
+				return fmt.Errorf("either 'git_repository', 'chart_repository', 'chart_url', 'chart_local_path' must be set")
+			}
+			if numFieldsSet != 1 {
+				return fmt.Errorf("only one of 'git_repository', 'chart_repository', 'chart_url', or 'chart_local_path' can be set")
+			}
+			if gitRefOk && !gitRepoOk {
+				return fmt.Errorf("'git_reference' can be used only with 'git_repository'")
+			}
+			// The release name is validated with the provider prefix and suffix
+			if config, ok := m.(*ProviderConfig); ok && (config.ReleaseNamePrefix != "" || config.ReleaseNameSuffix != "") && d.NewValueKnown("name") {
+				if err := validateReleaseName(config.releaseName(d.Get("name").(string))); err != nil {
+					return fmt.Errorf("'name' with the provider 'release_name_prefix' and 'release_name_suffix': %s", err)
+				}
+			}
+
+			// The default branch clone isn't reproducible, the unknown reference is pinned by another resource
+			if config, ok := m.(*ProviderConfig); ok && config.RequireGitReference && gitRepoOk && !gitRefOk && d.NewValueKnown("git_reference") {
+				return fmt.Errorf("'git_reference' is required with 'git_repository' since the provider 'require_git_reference' is set, pin the tag or the commit hash")
+			}
+			if (d.Get("git_sparse_checkout").(bool) || d.Get("git_submodules").(bool) || d.Get("git_lfs").(bool)) && !gitRepoOk {
+				return fmt.Errorf("'git_sparse_checkout', 'git_submodules' and 'git_lfs' can be used only with 'git_repository'")
+			}
+			if _, chartVersionOk := d.GetOk("chart_version"); chartVersionOk && !helmRepoOk && !chartShorthandRegexp.MatchString(d.Get("chart_url").(string)) {
+				return fmt.Errorf("'chart_version' can be used only with 'chart_repository' or the 'repo/chart' shorthand 'chart_url', the version of the other chart sources is defined by the source itself, e.g. 'git_reference' or the chart URL")
+			}
+			if d.Get("pin_resolved_version").(bool) && !helmRepoOk && !chartShorthandRegexp.MatchString(d.Get("chart_url").(string)) {
+				return fmt.Errorf("'pin_resolved_version' can be used only with 'chart_repository' or the 'repo/chart' shorthand 'chart_url', the version of the other chart sources is defined by the source itself")
+			}
+			if _, chartPathOk := d.GetOk("chart_path"); chartPathOk {
+				if chartLocalPathOk {
+					return fmt.Errorf("'chart_path' can't be used with 'chart_local_path', set the full chart path in 'chart_local_path'")
+				}
+				if subdir, packaged := packagedChartURL(d.Get("chart_url").(string)); packaged && subdir != "" {
+					return fmt.Errorf("'chart_path' can't be used with the packaged chart 'chart_url' subdirectory '%s', use only one of them", subdir)
+				}
+			}
+
+			if d.Get("require_namespace").(bool) && d.Get("create_namespace").(bool) {
+				return fmt.Errorf("'require_namespace' can't be used with 'create_namespace'")
+			}
+
+			_, nsLabelsOk := d.GetOk("namespace_labels")
+			_, nsAnnotationsOk := d.GetOk("namespace_annotations")
+			if (nsLabelsOk || nsAnnotationsOk) && !d.Get("create_namespace").(bool) {
+				return fmt.Errorf("'namespace_labels' and 'namespace_annotations' can be used only with 'create_namespace'")
+			}
+
+			if _, descriptionOk := d.GetOk("uninstall_description"); descriptionOk && !d.Get("keep_history").(bool) {
+				return fmt.Errorf("'uninstall_description' can be used only with 'keep_history'")
+			}
+
+			// Plan the upgrade of the failed or stuck release
+			if d.Id() != "" && releaseStatusUnhealthy(d.Get("release_status").(string)) {
+				if err := d.SetNewComputed("release_status"); err != nil {
+					return err
+				}
+			}
+
+			// Plan the upgrade of the release which manifest is changed outside of Terraform
+			if d.Id() != "" && d.Get("detect_manifest_drift").(bool) && d.Get("manifest_drifted").(bool) {
+				if err := d.SetNewComputed("manifest_hash"); err != nil {
+					return err
+				}
+			}
+
+			// Resolve the pinned version again, since the requested one is changed
+			if d.HasChanges("chart_version", "pin_resolved_version") {
+				if !d.Get("pin_resolved_version").(bool) {
+					if err := d.SetNew("resolved_chart_version", ""); err != nil {
+						return err
+					}
+				} else if err := d.SetNewComputed("resolved_chart_version"); err != nil {
+					return err
+				}
+			}
+
+			// The applied inputs are hashed again, the values are compared canonically since the StateFunc isn't applied to the new ones
+			oldValues, newValues := d.GetChange("values")
+			oldCanonical, _ := sanitizeYAMLString(oldValues.(string))
+			newCanonical, _ := sanitizeYAMLString(newValues.(string))
+			if d.Id() != "" && (d.HasChanges(configHashAttributes...) || oldCanonical != newCanonical) {
+				if err := d.SetNewComputed("config_hash"); err != nil {
+					return err
+				}
+			}
+
+			// Preview the object changes of the planned upgrade, the render failure doesn't block the plan
+			if config, ok := m.(*ProviderConfig); ok && config.PlanDiff && d.Id() != "" && len(d.GetChangedKeysPrefix("")) > 0 {
+				if home := d.Get("helm_config_home").(string); home != "" {
+					config = config.withHelmHome(home)
+				}
+				summary, err := planReleaseChanges(ctx, config, d)
+				if err != nil {
+					summary = fmt.Sprintf("Planned changes are unknown: %s", err)
+				}
+				return d.SetNew("planned_changes", summary)
+			}
+			return nil
+		},
+	}
+}
+
+// withHelmHome wraps the operation, so its Helm commands use the release 'helm_config_home' if it's set
+func withHelmHome(f func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics) func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics {
+	return func(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+		if home := d.Get("helm_config_home").(string); home != "" {
+			m = m.(*ProviderConfig).withHelmHome(home)
+		}
+		return f(ctx, d, m)
+	}
+}
+
+// withCommandTimeout wraps the operation, so the commands killed by the provider command timeout are explained
+func withCommandTimeout(f func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics) func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics {
+	return func(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+		start := time.Now()
+		diags := f(ctx, d, m)
+		return commandTimeoutDiags(diags, m.(*ProviderConfig).CommandTimeout, time.Since(start))
+	}
+}
+
+// withReleaseLock wraps the operation, so the operations on the same release don't interleave
+func withReleaseLock(f func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics) func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics {
+	return func(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+		unlock := m.(*ProviderConfig).lockRelease(d.Get("namespace").(string), helmReleaseName(d, m.(*ProviderConfig)))
+		defer unlock()
+		return f(ctx, d, m)
 	}
 }
 
 // resourceHelmReleaseDelete deletes Helm release
 func resourceHelmReleaseDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	name := d.Get("name").(string)
+	name := helmReleaseName(d, m.(*ProviderConfig))
 	namespace := d.Get("namespace").(string)
+	keepHistory := d.Get("keep_history").(bool)
+	uninstallDescription := d.Get("uninstall_description").(string)
+	deleteDryRun := d.Get("delete_dry_run").(bool)
+	deleteArgs := d.Get("delete_args").([]interface{})
 
 	config := m.(*ProviderConfig)
+
+	// Treat the already removed release as deleted, so destroy is idempotent
+	status, err := getHelmStatus(config, name, namespace, 0)
+	if err != nil && !releaseNotFound(err) {
+		return diag.FromErr(err)
+	}
+	if err != nil || status.Info.Status == "uninstalled" {
+		tflog.Info(ctx, fmt.Sprintf("Helm release '%s' is already uninstalled from the namespace '%s', skipping uninstall", name, namespace))
+		d.SetId("")
+		return nil
+	}
+
 	cmd := config.HelmCmd("uninstall", name, "--namespace", namespace)
-	output, err := cmd.CombinedOutput()
+	if keepHistory {
+		cmd.Args = append(cmd.Args, "--keep-history")
+		if uninstallDescription != "" {
+			cmd.Args = append(cmd.Args, "--description", uninstallDescription)
+		}
+	}
+	if deleteDryRun {
+		cmd.Args = append(cmd.Args, "--dry-run")
+	}
+	for _, arg := range deleteArgs {
+		cmd.Args = append(cmd.Args, arg.(string))
+	}
+	start := time.Now()
+	output, err := config.combinedOutput(cmd)
+	config.logHelmOperation(ctx, namespace, name, cmd, time.Since(start), err)
 	if err != nil {
-		return diag.FromErr(fmt.Errorf("failed to uninstall Helm release: %v, Output: %s", err, output))
+		return diag.FromErr(fmt.Errorf("failed to uninstall Helm release: %v, %s, Output: %s", err, exitStatusMessage(err), stripANSI(string(output))))
 	}
 
 	d.SetId("")
 
+	if deleteDryRun {
+		tflog.Info(ctx, fmt.Sprintf("Helm release '%s' dry-run uninstall from the namespace '%s': %s", name, namespace, stripANSI(string(output))))
+		return diag.Diagnostics{{
+			Severity: diag.Warning,
+			Summary:  "Helm release isn't uninstalled in the dry-run mode",
+			Detail:   fmt.Sprintf("Helm release '%s' is kept in the namespace '%s' since 'delete_dry_run' is set, but it's removed from the Terraform state", name, namespace),
+		}}
+	}
+
 	return nil
 }
 
-// resourceHelmReleaseRead reads Helm release state
-func resourceHelmReleaseRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	name := d.Get("name").(string)
+// resourceHelmReleaseRollback rolls back Helm release to the given revision, the previous one is used if revision is 0
+func resourceHelmReleaseRollback(ctx context.Context, d *schema.ResourceData, m interface{}, revision int) diag.Diagnostics {
+	name := helmReleaseName(d, m.(*ProviderConfig))
 	namespace := d.Get("namespace").(string)
+	wait := d.Get("wait").(bool)
+	timeout := d.Get("timeout").(string)
 
 	config := m.(*ProviderConfig)
+	cmd := config.HelmCmd("rollback", name, "--namespace", namespace)
+	if revision > 0 {
+		cmd.Args = append(cmd.Args, strconv.Itoa(revision))
+	}
+	if wait {
+		cmd.Args = append(cmd.Args, "--wait")
+	}
+	if timeout != "" {
+		cmd.Args = append(cmd.Args, "--timeout", normalizeTimeout(timeout))
+	}
 
-	tflog.Debug(ctx, "getting the Helm chart information")
-	listCmd := config.HelmCmd("list", "-n", namespace, "-f", name, "-o", "json")
-	output, err := listCmd.Output()
+	tflog.Info(ctx, fmt.Sprintf("Rolling back Helm release '%s' to revision: %d", name, revision))
+	start := time.Now()
+	output, err := config.combinedOutput(cmd)
+	config.logHelmOperation(ctx, namespace, name, cmd, time.Since(start), err)
 	if err != nil {
-		return diag.FromErr(fmt.Errorf("failed to retrieve Helm chart information: %s", err))
+		return diag.FromErr(fmt.Errorf("failed to roll back Helm release: %v, %s, Output: %s", err, exitStatusMessage(err), stripANSI(string(output))))
 	}
 
-	var helmList []struct {
-		Name       string `json:"name"`
-		Namespace  string `json:"namespace"`
-		Revision   string `json:"revision"`
-		Updated    string `json:"updated"`
-		Status     string `json:"status"`
-		Chart      string `json:"chart"`
-		AppVersion string `json:"app_version"`
+	status, err := getHelmStatus(config, name, namespace, 0)
+	if err != nil {
+		return diag.FromErr(err)
 	}
+	return readHelmRelease(ctx, d, m, 0, status.release())
+}
 
-	if err := json.Unmarshal(output, &helmList); err != nil {
-		return diag.FromErr(fmt.Errorf("failed to unmarshal Helm chart information: %s", err))
-	}
+// resourceHelmReleaseRead reads Helm release state, the revision recorded in the state and its computed values are kept
+// while it's in the history, so the state reflects the revision applied by Terraform after the out-of-band upgrade or rollback.
+// The values drift is detected against the latest revision
+func resourceHelmReleaseRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	revision, _ := strconv.Atoi(d.Get("release_revision").(string))
+	return readHelmRelease(ctx, d, m, revision, nil)
+}
 
-	if len(helmList) == 0 {
-		return diag.Errorf("failed to list Helm chart: %s", name)
+// readHelmRelease reads Helm release state, the values of the latest revision are read if revision is 0.
+// The applied release status of the install, upgrade or rollback is used as is, it's retrieved from Helm if it's nil.
+// The description is only in the status, so it's kept on refresh unless the recorded revision is changed
+func readHelmRelease(ctx context.Context, d *schema.ResourceData, m interface{}, revision int, applied *helmRelease) diag.Diagnostics {
+	name := helmReleaseName(d, m.(*ProviderConfig))
+	namespace := d.Get("namespace").(string)
+
+	config := m.(*ProviderConfig)
+
+	release := applied
+	if release == nil {
+		tflog.Debug(ctx, "getting the Helm chart information")
+		var err error
+		if release, err = getHelmRelease(ctx, config, name, namespace); err != nil {
+			return diag.FromErr(err)
+		}
 	}
 
-	helmChart := helmList[0]
+	// The recorded revision may be removed from the history by '--history-max', so the latest one is read then
+	valuesArgs := []string{"get", "values", "-n", namespace, name}
+	revisionValuesArgs := valuesArgs
+	stateRevision := release.Revision
+	if revision > 0 && strconv.Itoa(revision) != release.Revision {
+		if err := config.run(config.HelmCmd("status", name, "-n", namespace, "--revision", strconv.Itoa(revision))); err != nil {
+			tflog.Warn(ctx, fmt.Sprintf("Helm release '%s' revision %d isn't found, the latest revision %s values are read", name, revision, release.Revision))
+		} else {
+			tflog.Info(ctx, fmt.Sprintf("Helm release '%s' revision is changed outside of Terraform: %d to %s, the revision %d computed values are read", name, revision, release.Revision, revision))
+			revisionValuesArgs = append(append([]string{}, valuesArgs...), "--revision", strconv.Itoa(revision))
+			stateRevision = strconv.Itoa(revision)
+		}
+	}
 
 	// Capture the respective values from the cluster at current time
-	chartParts := strings.Split(helmChart.Chart, "-")
-	chartVersion := chartParts[len(chartParts)-1]
-	chartName := strings.Join(chartParts[:len(chartParts)-1], "-")
-	d.Set("release_chart_name", chartName)
-	d.Set("release_chart_version", chartVersion)
+	d.Set("release_chart_name", release.ChartName)
+	d.Set("release_chart_version", release.ChartVersion)
 
-	d.Set("release_revision", helmChart.Revision)
-	d.Set("release_status", helmChart.Status)
+	if applied != nil {
+		d.Set("release_description", release.Description)
+	} else if d.Get("release_revision").(string) != stateRevision {
+		d.Set("release_description", "")
+	}
+	d.Set("release_revision", stateRevision)
+	d.Set("release_status", release.Status)
+	d.Set("release_namespace", release.Namespace)
+	d.Set("release_updated", release.Updated)
+	d.Set("release_app_version", release.AppVersion)
+
+	// Report the broken release instead of treating it as healthy, CustomizeDiff plans the upgrade for it
+	var diags diag.Diagnostics
+	if releaseStatusUnhealthy(release.Status) {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("Helm release '%s' is in the '%s' state", name, release.Status),
+			Detail:   "The release needs remediation, check 'helm history' for the details. The terrahelm_release resource is upgraded on the next apply",
+		})
+	}
 
+	// The drift is detected against the latest revision, so the out-of-band upgrade is planned to be reverted
 	tflog.Debug(ctx, "getting user Helm values")
-	userValuesCmd := config.HelmCmd("get", "values", "-n", namespace, name, "-o", "yaml")
-	userValuesOutput, err := userValuesCmd.Output()
+	userValuesCmd := config.HelmCmd(append(valuesArgs, "-o", "yaml")...)
+	userValuesOutput, err := config.output(userValuesCmd)
 	if err != nil {
-		return diag.FromErr(fmt.Errorf("failed to retrieve Helm values: %s", err))
+		return append(diags, diag.FromErr(fmt.Errorf("failed to retrieve Helm values: %s", err))...)
 	}
 
 	safeVal, err := sanitizeYAMLString(string(userValuesOutput))
 	if err != nil {
-		return diag.FromErr(fmt.Errorf("failed to sanitize Helm release values: %s", err))
+		return append(diags, diag.FromErr(fmt.Errorf("failed to sanitize Helm release values: %s", err))...)
+	}
+
+	// the data source schema doesn't have the values attribute
+	desired, _ := d.Get("values").(string)
+	desiredVal, err := sanitizeYAMLString(desired)
+	if err != nil {
+		return append(diags, diag.FromErr(fmt.Errorf("failed to sanitize desired Helm values: %s", err))...)
+	}
+	// The ignored values are taken from the desired ones, so their cluster changes don't drift
+	ignoredPaths, _ := d.Get("ignore_values").([]interface{})
+	safeVal, err = ignoreValues(desiredVal, safeVal, ignoredPaths)
+	if err != nil {
+		return append(diags, diag.FromErr(fmt.Errorf("failed to ignore Helm release values: %s", err))...)
+	}
+	_, hasValuesFiles := d.GetOk("values_files")
+	drifted, err := valuesDrifted(desiredVal, safeVal, hasValuesFiles)
+	if err != nil {
+		return append(diags, diag.FromErr(fmt.Errorf("failed to compare Helm release values: %s", err))...)
+	}
+	// Only overwrite the desired values when the cluster differs, so the plan shows the drift
+	if drifted {
+		tflog.Info(ctx, fmt.Sprintf("Helm release values drift detected for: '%s'", name))
+		d.Set("values", safeVal)
 	}
-	d.Set("values", safeVal)
 
 	tflog.Debug(ctx, "getting release Helm values")
-	valuesCmd := config.HelmCmd("get", "values", "-n", namespace, name, "-a", "-o", "json")
-	valuesOutput, err := valuesCmd.Output()
+	valuesCmd := config.HelmCmd(append(revisionValuesArgs, "-a", "-o", "json")...)
+	valuesOutput, err := config.output(valuesCmd)
 	if err != nil {
-		return diag.FromErr(fmt.Errorf("failed to retrieve Helm release values: %s", err))
+		return append(diags, diag.FromErr(fmt.Errorf("failed to retrieve Helm release values: %s", err))...)
 	}
 
 	var rawValues map[string]interface{}
 	if err := json.Unmarshal(valuesOutput, &rawValues); err != nil {
-		return diag.FromErr(fmt.Errorf("failed to unmarshal Helm release values: %s", err))
+		return append(diags, diag.FromErr(fmt.Errorf("failed to unmarshal Helm release values: %s", err))...)
 	}
 
 	flatValuesMap, err := jsonMapToStringMap(rawValues)
 	if err != nil {
-		return diag.FromErr(fmt.Errorf("failed to convert Helm release values: %s", err))
+		return append(diags, diag.FromErr(fmt.Errorf("failed to convert Helm release values: %s", err))...)
 	}
 
 	if err := d.Set("release_values", flatValuesMap); err != nil {
-		return diag.FromErr(err)
+		return append(diags, diag.FromErr(err)...)
 	}
 
-	return nil
+	valuesJSON, err := compactJSON(valuesOutput)
+	if err != nil {
+		return append(diags, diag.FromErr(fmt.Errorf("failed to convert Helm release values to JSON: %s", err))...)
+	}
+	d.Set("release_values_json", valuesJSON)
+
+	tflog.Debug(ctx, "getting Helm release hooks")
+	hooks, err := getHelmHooks(config, name, namespace, 0)
+	if err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	if err := d.Set("release_hooks", hooks); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	tflog.Debug(ctx, "getting Helm release notes")
+	notes, err := getHelmNotes(config, name, namespace, 0)
+	if err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	d.Set("release_notes", notes)
+
+	// the data source schema doesn't have the manifest drift attributes
+	if detect, _ := d.Get("detect_manifest_drift").(bool); detect {
+		tflog.Debug(ctx, "getting Helm release manifest hash")
+		hash, err := getManifestHash(config, name, namespace)
+		if err != nil {
+			return append(diags, diag.FromErr(err)...)
+		}
+
+		switch applied := d.Get("manifest_hash").(string); {
+		case applied == "":
+			// The drift detection is enabled for the existing release, so the current manifest is the baseline
+			d.Set("manifest_hash", hash)
+			d.Set("manifest_drifted", false)
+		case applied != hash:
+			tflog.Info(ctx, fmt.Sprintf("Helm release manifest drift detected for: '%s'", name))
+			d.Set("manifest_drifted", true)
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("Helm release '%s' manifest is changed outside of Terraform", name),
+				Detail:   "The release manifest differs from the one applied by Terraform, the terrahelm_release resource is upgraded on the next apply",
+			})
+		default:
+			d.Set("manifest_drifted", false)
+		}
+	}
+
+	return diags
 }
 
 // resourceHelmReleaseCreateOrUpdate downloads and installs or upgrades a Helm chart from a given source
 func resourceHelmReleaseCreateOrUpdate(ctx context.Context, d *schema.ResourceData, m interface{}, isUpdate bool) diag.Diagnostics {
 	// Retrieve input parameters from the schema
-	name := d.Get("name").(string)
+	name := helmReleaseName(d, m.(*ProviderConfig))
 	chartRepository := d.Get("chart_repository").(string)
 	gitRepository := d.Get("git_repository").(string)
 	gitReference := d.Get("git_reference").(string)
+	gitSparseCheckout := d.Get("git_sparse_checkout").(bool)
+	gitSubmodules := d.Get("git_submodules").(bool)
+	gitLFS := d.Get("git_lfs").(bool)
+	gitCloneDepth := d.Get("git_clone_depth").(int)
+	gitSingleBranch := d.Get("git_single_branch").(bool)
 	insecure := d.Get("insecure").(bool)
 	chartPath := d.Get("chart_path").(string)
 	chartURL := d.Get("chart_url").(string)
+	chartLocalPath := d.Get("chart_local_path").(string)
 	namespace := d.Get("namespace").(string)
 	createNamespace := d.Get("create_namespace").(bool)
+	requireNamespace := d.Get("require_namespace").(bool)
+	namespaceLabels := d.Get("namespace_labels").(map[string]interface{})
+	namespaceAnnotations := d.Get("namespace_annotations").(map[string]interface{})
 	chartVersion := d.Get("chart_version").(string)
+	pinResolvedVersion := d.Get("pin_resolved_version").(bool)
+	resolvedChartVersion := d.Get("resolved_chart_version").(string)
+	repositoryUsername := d.Get("repository_username").(string)
+	repositoryPassword := d.Get("repository_password").(string)
+	repositoryCAFile := d.Get("repository_ca_file").(string)
+	passCredentials := d.Get("pass_credentials").(bool)
+	verify := d.Get("verify").(bool)
+	validateValuesSchema := d.Get("validate_values_schema").(bool)
+	keyring := d.Get("keyring").(string)
 	values := d.Get("values").(string)
 	valuesFiles := d.Get("values_files").([]interface{})
+	valuesFrom := d.Get("values_from").([]interface{})
+	setLiteral := d.Get("set_literal").([]interface{})
+	valuesFilesUsername := d.Get("values_files_username").(string)
+	valuesFilesPassword := d.Get("values_files_password").(string)
+	valuesFilesHeaders := d.Get("values_files_headers").(map[string]interface{})
 	wait := d.Get("wait").(bool)
+	waitOnInstall := d.Get("wait_on_install").(bool)
+	waitOnUpgrade := d.Get("wait_on_upgrade").(bool)
 	atomic := d.Get("atomic").(bool)
+	atomicOnInstall := d.Get("atomic_on_install").(bool)
 	timeout := d.Get("timeout").(string)
+	readinessTimeout := d.Get("readiness_timeout").(string)
+	waitFor := d.Get("wait_for").([]interface{})
+	dependencyRepositories := d.Get("dependency_repositories").([]interface{})
+	dependencyUpdateOnInstall := d.Get("dependency_update_on_install").(bool)
+	dependencyTimeout := d.Get("dependency_timeout").(string)
 	debug := d.Get("debug").(bool)
 	customArgs := d.Get("custom_args").([]interface{})
+	installArgs := d.Get("install_args").([]interface{})
+	upgradeArgs := d.Get("upgrade_args").([]interface{})
 	postRenderer := d.Get("post_renderer").(string)
 	postRendererURL := d.Get("post_renderer_url").(string)
+	postInstallCommand := d.Get("post_install_command").([]interface{})
+	postInstallFailOnError := d.Get("post_install_fail_on_error").(bool)
+	replace := d.Get("replace").(bool)
+	takeOwnership := d.Get("take_ownership").(bool)
+	rollbackTo := d.Get("rollback_to").(int)
+	rollbackOnFailure := d.Get("rollback_on_failure").(bool)
+	recreateOnFailed := d.Get("recreate_on_failed").(bool)
 
 	// Retrieve provider config
 	config := m.(*ProviderConfig)
 	cacheDir := config.CacheDir
+	repository := config.repositoryAuth(repositoryUsername, repositoryPassword, repositoryCAFile)
+	repositoryUsername, repositoryPassword = repository.Username, repository.Password
 
-	fullChartPath := filepath.Join(chartRepository, chartPath)
-	repoPath := ""
+	// Roll back to the given revision instead of upgrading
+	if isUpdate && rollbackTo > 0 && d.HasChange("rollback_to") {
+		return resourceHelmReleaseRollback(ctx, d, m, rollbackTo)
+	}
 
-	if chartRepository == "" {
-		repoPath = filepath.Join(cacheDir, "repos", name+"-"+generateHash(gitRepository+chartURL))
-		fullChartPath = filepath.Join(repoPath, chartPath)
+	// Install the 'repo/chart' shorthand from the added repository as Helm CLI does
+	if repo, chart, ok := resolveChartShorthand(config, chartURL); ok {
+		tflog.Debug(ctx, fmt.Sprintf("Using the chart '%s' of the added repository '%s'", chart, repo))
+		chartRepository, chartPath, chartURL = repo, chart, ""
+	} else if chartURL != "" && chartVersion != "" {
+		return diag.FromErr(fmt.Errorf("'chart_version' can't be used with 'chart_url' '%s', the repository '%s' isn't added", chartURL, strings.SplitN(chartURL, "/", 2)[0]))
+	}
 
-		tflog.Debug(ctx, fmt.Sprintf("Initializing repo directory: '%s'...", repoPath))
+	// Keep the pinned version of the repository chart unless the version is exact or changed
+	if pinResolvedVersion && isUpdate && chartRepository != "" && resolvedChartVersion != "" && !d.HasChange("chart_version") && !exactVersion(chartVersion) {
+		tflog.Debug(ctx, fmt.Sprintf("Using the pinned chart version: '%s'", resolvedChartVersion))
+		chartVersion = resolvedChartVersion
+	}
 
-		// Remove existing repo path if it exists
-		if gitRepository != "" {
-			if _, err := os.Stat(repoPath); err == nil {
-				if err := os.RemoveAll(repoPath); err != nil {
-					return diag.FromErr(fmt.Errorf("failed to delete existing directory: %s", err))
-				}
-			}
+	// Fail early on the missing namespace, rather than in the middle of the chart installation
+	if requireNamespace && !createNamespace {
+		tflog.Debug(ctx, fmt.Sprintf("Checking the namespace exists: '%s'...", namespace))
+		if err := checkNamespaceExists(ctx, config, namespace); err != nil {
+			return diag.FromErr(fmt.Errorf("namespace precondition failed: %s", err))
 		}
+	}
 
-		// Create repo path directory
-		if err := os.MkdirAll(repoPath, os.ModePerm); err != nil {
-			return diag.FromErr(fmt.Errorf("failed to create the directory: %s", err))
+	spec := chartSpec{
+		Name:                      name,
+		ChartRepository:           chartRepository,
+		ChartPath:                 chartPath,
+		ChartURL:                  chartURL,
+		ChartLocalPath:            chartLocalPath,
+		GitRepository:             gitRepository,
+		GitReference:              gitReference,
+		GitCloneDepth:             gitCloneDepth,
+		GitSingleBranch:           gitSingleBranch,
+		GitSparseCheckout:         gitSparseCheckout,
+		GitSubmodules:             gitSubmodules,
+		GitLFS:                    gitLFS,
+		ValuesFiles:               valuesFiles,
+		Insecure:                  insecure,
+		DependencyRepositories:    dependencyRepositories,
+		DependencyUpdateOnInstall: dependencyUpdateOnInstall,
+	}
+	if dependencyTimeout != "" {
+		var err error
+		if spec.DependencyTimeout, err = time.ParseDuration(normalizeTimeout(dependencyTimeout)); err != nil {
+			return diag.FromErr(fmt.Errorf("invalid 'dependency_timeout': %s", err))
 		}
-
-		// Clone Git repository if specified
-		if gitRepository != "" {
-			cloneArgs := []string{"clone", "--depth", "1", "--single-branch"}
-			if insecure {
-				cloneArgs = append(cloneArgs, "-c", "http.sslVerify=false")
-			}
-			cloneArgs = append(cloneArgs, "--branch", gitReference, gitRepository, repoPath)
-			cloneCmd := exec.Command(config.GitBinPath, cloneArgs...)
-			var cloneCmdStderr bytes.Buffer
-			cloneCmd.Stderr = &cloneCmdStderr
-			tflog.Info(ctx, fmt.Sprintf("Git Repository cloning: '%s'...", gitRepository))
-			if err := cloneCmd.Run(); err != nil {
-				return diag.FromErr(fmt.Errorf("failed to clone the Git repository: %s\nCommand output: %s", err, cloneCmdStderr.String()))
-			}
+	}
+	repoPath := spec.repoPath(cacheDir)
+	registryConfig := ""
+
+	// Log in to the registry for the operation only, so the credentials aren't left in the registry config
+	if ociRepository(chartRepository) && chartLocalPath == "" && repositoryUsername != "" {
+		var logout func()
+		var err error
+		if registryConfig, logout, err = registryLogin(ctx, config, chartRepository, repositoryUsername, repositoryPassword, repository.CAFile, insecure); err != nil {
+			return diag.FromErr(err)
 		}
+		defer logout()
+	}
 
-		// Download chart from URL if specified
-		if chartURL != "" {
-			client := &getter.Client{
-				Src:      chartURL,
-				Dst:      repoPath,
-				Insecure: insecure,
-				Mode:     getter.ClientModeAny,
-			}
-
-			tflog.Info(ctx, fmt.Sprintf("Chart URL downloading: '%s' to '%s'...", chartURL, repoPath))
-			if err := client.Get(); err != nil {
-				return diag.FromErr(fmt.Errorf("failed to fetch the repository: %s\nError: %s", gitRepository, err))
-			}
-		}
+	fullChartPath, err := resolveChart(ctx, config, spec)
+	if err != nil {
+		return chartErrorDiags(err)
+	}
 
-		// Build Helm dependency
-		depCmd := config.HelmCmd("dependency", "build", fullChartPath)
-		var helmDepStderr bytes.Buffer
-		depCmd.Stderr = &helmDepStderr
-		tflog.Debug(ctx, fmt.Sprintf("Building Helm dependency: '%s'...", fullChartPath))
-		if err := depCmd.Run(); err != nil {
-			return diag.FromErr(fmt.Errorf("failed to run 'helm dependency build': %s\nHelm output: %s", err, helmDepStderr.String()))
+	// Recreate the failed release, since the upgrade often can't recover it
+	recreate := false
+	if isUpdate && recreateOnFailed {
+		status, err := getHelmStatus(config, name, namespace, 0)
+		if err != nil {
+			return diag.FromErr(err)
 		}
+		recreate = status.Info.Status == "failed"
 	}
 
 	// Install or upgrade the Helm chart
 	cmd := "install"
-	if isUpdate {
+	if isUpdate && !recreate {
 		cmd = "upgrade"
 	}
 	helmCmd := config.HelmCmd(cmd, name, fullChartPath)
 
+	// Run Helm in the release working directory, so the relative paths of the custom args are resolved predictably
+	workDir := releaseWorkDir(cacheDir, name, repoPath, chartLocalPath)
+	if err := os.MkdirAll(workDir, os.ModePerm); err != nil {
+		return diag.FromErr(fmt.Errorf("failed to create the Helm working directory: %s", err))
+	}
+	helmCmd.Dir = workDir
+
 	// Prepare values
 	valuesPath := filepath.Join(cacheDir, "values", name)
 	if values != "" || len(valuesFiles) > 0 {
@@ -434,27 +1381,35 @@ func resourceHelmReleaseCreateOrUpdate(ctx context.Context, d *schema.ResourceDa
 	}
 
 	// Handle values files
+	appliedValuesFiles := []string{}
 	if len(valuesFiles) > 0 {
 		var vfPaths []string
+		valuesFilesHeader := valuesFilesAuthHeader(valuesFilesUsername, valuesFilesPassword, valuesFilesHeaders)
 
 		for _, v := range valuesFiles {
 			vf := v.(string)
 			if strings.HasPrefix(vf, ".") && repoPath != "" {
-				vfPaths = append(vfPaths, filepath.Join(repoPath, vf))
+				vfPath, err := securePath(repoPath, vf)
+				if err != nil {
+					return diag.FromErr(fmt.Errorf("invalid values file '%s': %s", vf, err))
+				}
+				vfPaths = append(vfPaths, vfPath)
+				if relPath, err := filepath.Rel(repoPath, vfPath); err == nil {
+					appliedValuesFiles = append(appliedValuesFiles, relPath)
+				}
 			} else {
 				vDst := path.Join(valuesPath, fmt.Sprintf("%s-%s-values.yaml", name, generateHash(vf)))
-				client := &getter.Client{
-					Src:      vf,
-					Dst:      vDst,
-					Insecure: insecure,
-					Mode:     getter.ClientModeFile,
-				}
+				client := newHeaderGetterClient(config, withAzureSASToken(vf), vDst, getter.ClientModeFile, insecure, valuesFilesHeader)
 
-				tflog.Info(ctx, fmt.Sprintf("Value File downloading: '%s' to '%s'...", vf, vDst))
+				tflog.Info(ctx, fmt.Sprintf("Value File downloading: '%s' to '%s'...", redactURL(vf), vDst))
 				if err := client.Get(); err != nil {
-					return diag.FromErr(fmt.Errorf("failed to fetch the repository: %s\nError: %s", gitRepository, err))
+					if credsErr := cloudCredentialsError(vf, err); credsErr != err {
+						return diag.FromErr(credsErr)
+					}
+					return diag.FromErr(fmt.Errorf("failed to fetch the values file: %s\nError: %s", redactURL(vf), strings.ReplaceAll(err.Error(), client.Src, redactURL(client.Src))))
 				}
 				vfPaths = append(vfPaths, vDst)
+				appliedValuesFiles = append(appliedValuesFiles, redactURL(vf))
 			}
 		}
 
@@ -463,6 +1418,19 @@ func resourceHelmReleaseCreateOrUpdate(ctx context.Context, d *schema.ResourceDa
 		}
 	}
 
+	// Handle values from the cluster, they may be secret so the files are removed after the operation
+	if len(valuesFrom) > 0 {
+		vfPaths, cleanup, err := writeValuesFrom(ctx, config, valuesFrom, name, namespace)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		defer cleanup()
+
+		for _, v := range vfPaths {
+			helmCmd.Args = append(helmCmd.Args, "-f", v)
+		}
+	}
+
 	// Handle values string
 	if values != "" {
 		valuesPath := filepath.Join(cacheDir, "values", chartRepository)
@@ -485,30 +1453,76 @@ func resourceHelmReleaseCreateOrUpdate(ctx context.Context, d *schema.ResourceDa
 		helmCmd.Args = append(helmCmd.Args, "-f", valuesFilePath)
 	}
 
+	// Handle literal values
+	if len(setLiteral) > 0 {
+		if err := config.checkHelmVersion("set_literal", setLiteralHelmVersion); err != nil {
+			return diag.FromErr(err)
+		}
+		helmCmd.Args = append(helmCmd.Args, setLiteralArgs(setLiteral)...)
+	}
+
 	// Append additional Helm command arguments
 	if namespace != "" {
 		helmCmd.Args = append(helmCmd.Args, "--namespace", namespace)
 	}
-	if createNamespace {
+	// The flag is applied by install only, the namespace of the upgraded release exists already
+	if createNamespace && cmd == "install" {
 		helmCmd.Args = append(helmCmd.Args, "--create-namespace")
 	}
+	if replace && cmd == "install" {
+		helmCmd.Args = append(helmCmd.Args, "--replace")
+	}
+	if takeOwnership {
+		if err := config.checkHelmVersion("take_ownership", takeOwnershipHelmVersion); err != nil {
+			return diag.FromErr(err)
+		}
+		helmCmd.Args = append(helmCmd.Args, "--take-ownership")
+	}
+	if dependencyUpdateOnInstall {
+		helmCmd.Args = append(helmCmd.Args, "--dependency-update")
+	}
 	if chartVersion != "" {
 		helmCmd.Args = append(helmCmd.Args, "--version", chartVersion)
 	}
-	if wait {
+	if insecure && ociRepository(chartRepository) {
+		helmCmd.Args = append(helmCmd.Args, "--insecure-skip-tls-verify")
+	}
+	if registryConfig != "" {
+		helmCmd.Args = append(helmCmd.Args, "--registry-config", registryConfig)
+	} else {
+		if repositoryUsername != "" {
+			helmCmd.Args = append(helmCmd.Args, "--username", repositoryUsername)
+		}
+		if repositoryPassword != "" {
+			helmCmd.Args = append(helmCmd.Args, "--password", repositoryPassword)
+		}
+	}
+	if passCredentials {
+		helmCmd.Args = append(helmCmd.Args, "--pass-credentials")
+	}
+	if repository.CAFile != "" {
+		helmCmd.Args = append(helmCmd.Args, "--ca-file", repository.CAFile)
+	}
+	if verify {
+		if err := checkChartProvenance(fullChartPath); err != nil {
+			return diag.FromErr(err)
+		}
+		helmCmd.Args = append(helmCmd.Args, "--verify")
+		if keyring != "" {
+			helmCmd.Args = append(helmCmd.Args, "--keyring", absPath(keyring))
+		}
+	}
+	if wait || (cmd == "install" && waitOnInstall) || (cmd == "upgrade" && waitOnUpgrade) {
 		helmCmd.Args = append(helmCmd.Args, "--wait")
 	}
-	if atomic {
+	if atomic && (cmd == "upgrade" || atomicOnInstall) {
 		helmCmd.Args = append(helmCmd.Args, "--atomic")
 	}
 	if debug {
 		helmCmd.Args = append(helmCmd.Args, "--debug")
 	}
 	if timeout != "" {
-		if _, err := strconv.Atoi(timeout); err == nil {
-			timeout = timeout + "s"
-		}
-		helmCmd.Args = append(helmCmd.Args, "--timeout", timeout)
+		helmCmd.Args = append(helmCmd.Args, "--timeout", normalizeTimeout(timeout))
 	}
 
 	renderPath := ""
@@ -527,11 +1541,7 @@ func resourceHelmReleaseCreateOrUpdate(ctx context.Context, d *schema.ResourceDa
 			getDst = filepath.Join(renderPath, defaultPostRenderer)
 		}
 
-		client := &getter.Client{
-			Src:  postRendererURL,
-			Dst:  getDst,
-			Mode: getMode,
-		}
+		client := newGetterClient(config, postRendererURL, getDst, getMode, false)
 
 		tflog.Info(ctx, fmt.Sprintf("Downloading post-renderer script from '%s' to '%s'", postRendererURL, renderPath))
 		if err := client.Get(); err != nil {
@@ -558,35 +1568,1752 @@ func resourceHelmReleaseCreateOrUpdate(ctx context.Context, d *schema.ResourceDa
 		}
 	}
 
-	// Append custom arguments
-	for _, arg := range customArgs {
+	// The operation arguments go after the common ones
+	if cmd == "install" {
+		customArgs = append(append([]interface{}{}, customArgs...), installArgs...)
+	} else {
+		customArgs = append(append([]interface{}{}, customArgs...), upgradeArgs...)
+	}
+
+	// Print the release as JSON to store it without the follow-up status, unless the output is set in custom arguments
+	jsonOutput := !hasOutputArg(customArgs)
+	if jsonOutput {
+		helmCmd.Args = append(helmCmd.Args, "-o", "json")
+	}
+
+	// Append custom arguments
+	for _, arg := range customArgs {
 		helmCmd.Args = append(helmCmd.Args, arg.(string))
 	}
 
-	// Execute Helm command
-	var helmCmdStdout, helmCmdStderr bytes.Buffer
-	helmCmd.Stderr = &helmCmdStderr
-	helmCmd.Stdout = &helmCmdStdout
-	helmCmdString := strings.Join(helmCmd.Args, " ")
-	tflog.Info(ctx, fmt.Sprintf("\n\nRunning Helm command:\n  %s\n\n", helmCmdString))
-	if err := helmCmd.Run(); err != nil {
-		errMsg := fmt.Sprintf("failed to %s the Helm chart: %s\nHelm command: %s\nHelm output: %s", cmd, err, helmCmdString, helmCmdStderr.String())
-		if debug {
-			errMsg += fmt.Sprintf("\nHelm stdout: %s", helmCmdStdout.String())
-			errMsg += fmt.Sprintf("\nHelm stderr: %s", helmCmdStderr.String())
+	// Fail before the Helm command with all the schema errors, the remote charts are validated by Helm itself
+	if validateValuesSchema {
+		if _, err := os.Stat(fullChartPath); err != nil {
+			tflog.Warn(ctx, fmt.Sprintf("Skipping the values schema validation of the remote chart: '%s'", fullChartPath))
+		} else {
+			tflog.Info(ctx, fmt.Sprintf("Validating the values against the chart schema: '%s'...", fullChartPath))
+			if err := validateChartValues(fullChartPath, valuesFileArgs(helmCmd.Args)); err != nil {
+				return diag.FromErr(fmt.Errorf("failed to validate the Helm values: %s", err))
+			}
+		}
+	}
+
+	// Create the namespace with metadata beforehand, since Helm doesn't support it
+	if createNamespace && (len(namespaceLabels) > 0 || len(namespaceAnnotations) > 0) {
+		tflog.Info(ctx, fmt.Sprintf("Applying namespace metadata: '%s'...", namespace))
+		if err := applyNamespaceMetadata(ctx, config, namespace, namespaceLabels, namespaceAnnotations); err != nil {
+			return diag.FromErr(fmt.Errorf("failed to apply namespace metadata: %s", err))
+		}
+	}
+
+	if recreate {
+		tflog.Info(ctx, fmt.Sprintf("Uninstalling the failed Helm release before the installation: '%s'...", name))
+		uninstallCmd := config.HelmCmd("uninstall", name, "--namespace", namespace)
+		if wait {
+			uninstallCmd.Args = append(uninstallCmd.Args, "--wait")
+		}
+		start := time.Now()
+		output, err := config.combinedOutput(uninstallCmd)
+		config.logHelmOperation(ctx, namespace, name, uninstallCmd, time.Since(start), err)
+		if err != nil {
+			return diag.FromErr(fmt.Errorf("failed to uninstall the failed Helm release: %v, Output: %s", err, stripANSI(string(output))))
+		}
+	}
+
+	// Execute Helm command
+	var helmCmdStdout, helmCmdStderr bytes.Buffer
+	helmCmd.Stderr = &helmCmdStderr
+	helmCmd.Stdout = &helmCmdStdout
+	helmCmdString := redactArgs(helmCmd.Args)
+	tflog.Info(ctx, fmt.Sprintf("\n\nRunning Helm command:\n  %s\n\n", helmCmdString))
+	start := time.Now()
+	err = config.run(helmCmd)
+	// The command can't be run twice, so it's recreated with the same arguments
+	rerun := func() {
+		helmCmd = cloneCmd(helmCmd)
+		helmCmdStdout.Reset()
+		helmCmdStderr.Reset()
+		helmCmd.Stderr = &helmCmdStderr
+		helmCmd.Stdout = &helmCmdStdout
+		start = time.Now()
+		err = config.run(helmCmd)
+	}
+	for attempt := 1; err != nil && !createNamespace && namespaceNotFound(helmCmdStderr.String(), namespace) && attempt <= namespaceRetryAttempts; attempt++ {
+		tflog.Warn(ctx, fmt.Sprintf("Namespace '%s' is not found, retrying the Helm command in %s (attempt %d/%d)...", namespace, namespaceRetryDelay, attempt, namespaceRetryAttempts))
+		select {
+		case <-ctx.Done():
+			return diag.FromErr(fmt.Errorf("failed to %s the Helm chart: namespace '%s' is not found: %s", cmd, namespace, ctx.Err()))
+		case <-time.After(namespaceRetryDelay):
+		}
+		rerun()
+	}
+	// The pending operation is usually the '--atomic' rollback of the previous failed apply
+	for attempt := 1; err != nil && operationInProgress(helmCmdStderr.String()) && attempt <= operationRetryAttempts; attempt++ {
+		tflog.Warn(ctx, fmt.Sprintf("Another operation is in progress on the Helm release '%s', waiting for it to finish (attempt %d/%d)...", name, attempt, operationRetryAttempts))
+		if waitErr := waitForPendingOperation(ctx, config, name, namespace); waitErr != nil {
+			tflog.Warn(ctx, fmt.Sprintf("Failed to wait for the pending operation of the Helm release '%s': %s", name, waitErr))
+			break
+		}
+		rerun()
+	}
+	duration := time.Since(start)
+	config.logHelmOperation(ctx, namespace, name, helmCmd, duration, err)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to %s the Helm chart: %s\n%s\nHelm command: %s\nHelm output: %s", cmd, err, exitStatusMessage(err), helmCmdString, stripANSI(helmCmdStderr.String()))
+		if verify && strings.Contains(helmCmdStderr.String(), "openpgp") {
+			errMsg += "\nChart verification failed, make sure the chart is signed with a key from the keyring"
+		}
+		if debug {
+			errMsg += fmt.Sprintf("\nHelm stdout: %s", stripANSI(helmCmdStdout.String()))
+			errMsg += fmt.Sprintf("\nHelm stderr: %s", stripANSI(helmCmdStderr.String()))
+		}
+		diags := diag.FromErr(fmt.Errorf(errMsg))
+
+		// Roll back to the last deployed revision to keep the release consistent, '--atomic' has already rolled it back
+		if cmd == "upgrade" && rollbackOnFailure && !atomic {
+			rollbackDiags := resourceHelmReleaseRollback(ctx, d, m, 0)
+			if !rollbackDiags.HasError() {
+				rollbackDiags = append(rollbackDiags, diag.Diagnostic{
+					Severity: diag.Warning,
+					Summary:  "Helm release has been rolled back after the failed upgrade",
+					Detail:   fmt.Sprintf("Helm release '%s' has been rolled back to revision %s, status: %s", name, d.Get("release_revision"), d.Get("release_status")),
+				})
+			}
+			diags = append(diags, rollbackDiags...)
+		}
+
+		return diags
+	}
+
+	// Set the ID for the new resource, the ID of the existing one is kept
+	if !isUpdate || d.Id() == "" {
+		d.SetId(config.releaseID(namespace, name))
+	}
+	d.Set("last_operation_duration", duration.Seconds())
+	d.Set("applied_values_files", appliedValuesFiles)
+	if hash, err := configHash(d, chartVersion); err != nil {
+		tflog.Warn(ctx, fmt.Sprintf("Failed to hash the Helm release config: %s", err))
+	} else {
+		d.Set("config_hash", hash)
+	}
+
+	// The parsed release is stored without the extra Helm calls, it's retrieved from Helm if the output can't be parsed
+	var release *helmStatus
+	if jsonOutput {
+		if release, err = parseHelmReleaseOutput(helmCmdStdout.Bytes()); err != nil {
+			tflog.Warn(ctx, fmt.Sprintf("Failed to parse the Helm release output, falling back to 'helm status': %s", err))
+		}
+	}
+	if release == nil {
+		if release, err = getHelmStatus(config, name, namespace, 0); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	if pinResolvedVersion {
+		d.Set("resolved_chart_version", release.Chart.Metadata.Version)
+	}
+
+	// Store the deployed chart metadata, it's informational so the failure isn't fatal
+	var showArgs []string
+	if chartVersion != "" {
+		showArgs = append(showArgs, "--version", chartVersion)
+	}
+	if insecure && ociRepository(chartRepository) {
+		showArgs = append(showArgs, "--insecure-skip-tls-verify")
+	}
+	if registryConfig != "" {
+		showArgs = append(showArgs, "--registry-config", registryConfig)
+	} else {
+		if repositoryUsername != "" {
+			showArgs = append(showArgs, "--username", repositoryUsername)
+		}
+		if repositoryPassword != "" {
+			showArgs = append(showArgs, "--password", repositoryPassword)
+		}
+	}
+	if passCredentials {
+		showArgs = append(showArgs, "--pass-credentials")
+	}
+	if repository.CAFile != "" {
+		showArgs = append(showArgs, "--ca-file", repository.CAFile)
+	}
+	if metadata, err := getChartMetadata(config, fullChartPath, showArgs...); err != nil {
+		tflog.Warn(ctx, fmt.Sprintf("Failed to get the chart metadata: %s", err))
+	} else {
+		d.Set("chart_metadata", []interface{}{metadata.toMap()})
+	}
+
+	// The JSON output contains the manifest and the values, so only the notes are logged
+	if config.HelmQuiet {
+		log.Printf("Helm chart %s has been %s(ed) successfully", name, cmd)
+	} else if jsonOutput {
+		log.Printf("Helm chart %s has been %s(ed) successfully, revision: %d, status: %s. Helm notes:\n%s", name, cmd, release.Version, release.Info.Status, stripANSI(release.Info.Notes))
+	} else {
+		log.Printf("Helm chart %s has been %s(ed) successfully. Helm output:\n%s", name, cmd, stripANSI(helmCmdStdout.String()))
+	}
+
+	// Poll the resources Helm doesn't track until they're ready
+	if len(waitFor) > 0 {
+		waitTimeout := waitForDefaultTimeout
+		if timeout != "" {
+			var err error
+			if waitTimeout, err = time.ParseDuration(normalizeTimeout(timeout)); err != nil {
+				return diag.FromErr(fmt.Errorf("invalid 'timeout': %s", err))
+			}
+		}
+
+		tflog.Info(ctx, fmt.Sprintf("Waiting for %d resources of the Helm release '%s'...", len(waitFor), name))
+		if err := waitForResources(ctx, config, name, namespace, waitFor, waitTimeout); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	// Fail the apply instead of leaving the degraded release, since Helm doesn't fail it without 'atomic'
+	if readinessTimeout != "" {
+		readyTimeout, err := time.ParseDuration(normalizeTimeout(readinessTimeout))
+		if err != nil {
+			return diag.FromErr(fmt.Errorf("invalid 'readiness_timeout': %s", err))
+		}
+
+		tflog.Info(ctx, fmt.Sprintf("Waiting for the Helm release '%s' to be deployed...", name))
+		if err := waitForDeployed(ctx, config, name, namespace, readyTimeout); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	// Store the applied manifest as the baseline of the drift detection
+	if d.Get("detect_manifest_drift").(bool) {
+		hash, err := getManifestHash(config, name, namespace)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		d.Set("manifest_hash", hash)
+		d.Set("manifest_drifted", false)
+	} else {
+		d.Set("manifest_hash", "")
+	}
+
+	// Read the release status to update the Terraform state
+	diags := readHelmRelease(ctx, d, m, 0, release.release())
+	if diags.HasError() || len(postInstallCommand) == 0 {
+		return diags
+	}
+
+	if err := runPostInstallCommand(ctx, config, postInstallCommand, name, namespace, d.Get("release_revision").(string)); err != nil {
+		if postInstallFailOnError {
+			return append(diags, diag.FromErr(err)...)
+		}
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Post-install command failed",
+			Detail:   err.Error(),
+		})
+	}
+
+	return diags
+}
+
+// runPostInstallCommand runs the post-install command with the release passed by the environment variables
+func runPostInstallCommand(ctx context.Context, config *ProviderConfig, command []interface{}, name, namespace, revision string) error {
+	args := make([]string, len(command))
+	for i, arg := range command {
+		args[i] = arg.(string)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = append(os.Environ(),
+		"TH_RELEASE_NAME="+name,
+		"TH_RELEASE_NAMESPACE="+namespace,
+		"TH_RELEASE_REVISION="+revision,
+	)
+
+	tflog.Info(ctx, fmt.Sprintf("Running the post-install command of the Helm release '%s': %s", name, redactArgs(args)))
+	start := time.Now()
+	output, err := config.combinedOutput(cmd)
+	if err != nil {
+		if config.CommandTimeout > 0 && time.Since(start) >= config.CommandTimeout {
+			return fmt.Errorf("post-install command '%s' timed out after %s, increase the provider 'command_timeout'\nOutput: %s", redactArgs(args), config.CommandTimeout, stripANSI(string(output)))
+		}
+		return fmt.Errorf("post-install command '%s' failed: %s\nOutput: %s", redactArgs(args), err, stripANSI(string(output)))
+	}
+	tflog.Info(ctx, fmt.Sprintf("Post-install command output:\n%s", stripANSI(string(output))))
+
+	return nil
+}
+
+// newGetterClient creates go-getter client using the provider HTTP client for HTTP downloads
+func newGetterClient(config *ProviderConfig, src, dst string, mode getter.ClientMode, insecure bool) *getter.Client {
+	return newHeaderGetterClient(config, src, dst, mode, insecure, nil)
+}
+
+// newHeaderGetterClient creates go-getter client sending the given headers with the HTTP requests, e.g. the credentials
+func newHeaderGetterClient(config *ProviderConfig, src, dst string, mode getter.ClientMode, insecure bool, header http.Header) *getter.Client {
+	client := &getter.Client{
+		Src:      src,
+		Dst:      dst,
+		Insecure: insecure,
+		Mode:     mode,
+	}
+
+	if config.HTTPClient != nil || len(header) > 0 {
+		httpGetter := &getter.HttpGetter{
+			Netrc:  true,
+			Header: header,
+		}
+		if config.HTTPClient != nil {
+			httpGetter.Client = config.HTTPClient
+			if insecure {
+				httpGetter.Client = insecureHTTPClient(config.HTTPClient)
+			}
+		}
+
+		client.Getters = make(map[string]getter.Getter, len(getter.Getters))
+		for name, g := range getter.Getters {
+			client.Getters[name] = g
+		}
+		client.Getters["http"] = httpGetter
+		client.Getters["https"] = httpGetter
+	}
+
+	return client
+}
+
+// valuesFilesAuthHeader returns the HTTP headers of the values files downloads, the basic auth is used if the username is set
+func valuesFilesAuthHeader(username, password string, headers map[string]interface{}) http.Header {
+	header := make(http.Header)
+	for name, value := range headers {
+		header.Set(name, value.(string))
+	}
+	if username != "" {
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+	}
+	return header
+}
+
+// cloudCredentials are the go-getter protocols of the cloud storages with the errors of the missing credentials and the settings to pass them
+var cloudCredentials = map[string]struct {
+	name     string
+	errMatch string
+	env      []string
+	hint     string
+}{
+	"s3": {
+		name:     "S3",
+		errMatch: "NoCredentialProviders",
+		env:      []string{"AWS_ACCESS_KEY_ID", "AWS_PROFILE", "AWS_WEB_IDENTITY_TOKEN_FILE", "AWS_CONTAINER_CREDENTIALS_FULL_URI", "AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"},
+		hint:     "set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, AWS_PROFILE or the 'aws_access_key_id' and 'aws_access_key_secret' URL parameters",
+	},
+	"gcs": {
+		name:     "GCS",
+		errMatch: "could not find default credentials",
+		env:      []string{"GOOGLE_APPLICATION_CREDENTIALS", "GOOGLE_OAUTH_ACCESS_TOKEN"},
+		hint:     "set GOOGLE_APPLICATION_CREDENTIALS or GOOGLE_OAUTH_ACCESS_TOKEN",
+	},
+	"azure": {
+		name:     "Azure Blob Storage",
+		errMatch: "bad response code",
+		env:      []string{azureSASTokenEnv},
+		hint:     "set " + azureSASTokenEnv + " or the SAS token query of the URL",
+	},
+}
+
+// azureSASTokenEnv is the environment variable of the SAS token appended to the Azure Blob Storage URLs
+const azureSASTokenEnv = "AZURE_STORAGE_SAS_TOKEN"
+
+// getterProtocol returns the go-getter protocol of the source, the Azure Blob Storage URLs are downloaded over HTTPS and reported as 'azure'
+func getterProtocol(src string) string {
+	detected, err := getter.Detect(src, "", getter.Detectors)
+	if err != nil {
+		return ""
+	}
+	if forced, rest, ok := strings.Cut(detected, "::"); ok {
+		if forced != "http" && forced != "https" {
+			return forced
+		}
+		detected = rest
+	}
+
+	u, err := url.Parse(detected)
+	if err != nil {
+		return ""
+	}
+	if strings.HasSuffix(u.Hostname(), ".blob.core.windows.net") {
+		return "azure"
+	}
+	return u.Scheme
+}
+
+// withAzureSASToken appends the SAS token of the environment to the Azure Blob Storage URL having no signature
+func withAzureSASToken(src string) string {
+	token := strings.TrimPrefix(os.Getenv(azureSASTokenEnv), "?")
+	if token == "" || getterProtocol(src) != "azure" {
+		return src
+	}
+
+	u, err := url.Parse(src)
+	if err != nil || u.Query().Get("sig") != "" {
+		return src
+	}
+	if u.RawQuery != "" {
+		u.RawQuery += "&"
+	}
+	u.RawQuery += token
+	return u.String()
+}
+
+// cloudCredentialsError replaces the go-getter error of the cloud storage source having no credentials with the hint of the credentials settings
+func cloudCredentialsError(src string, err error) error {
+	protocol := getterProtocol(src)
+	creds, ok := cloudCredentials[protocol]
+	if !ok || !strings.Contains(err.Error(), creds.errMatch) {
+		return err
+	}
+	for _, env := range creds.env {
+		if os.Getenv(env) != "" {
+			return err
+		}
+	}
+	return fmt.Errorf("%s credentials are not found for '%s': %s", creds.name, redactURL(src), creds.hint)
+}
+
+// chartSpec is the chart source of the Helm release resolved by resolveChart
+type chartSpec struct {
+	// Name of the Helm release, it names the download directory of the chart source
+	Name                      string
+	ChartRepository           string
+	ChartPath                 string
+	ChartURL                  string
+	ChartLocalPath            string
+	GitRepository             string
+	GitReference              string
+	GitCloneDepth             int
+	GitSingleBranch           bool
+	GitSparseCheckout         bool
+	GitSubmodules             bool
+	GitLFS                    bool
+	ValuesFiles               []interface{}
+	Insecure                  bool
+	DependencyRepositories    []interface{}
+	DependencyUpdateOnInstall bool
+	// DependencyTimeout overrides the provider command timeout of 'helm dependency build'
+	DependencyTimeout time.Duration
+}
+
+// repoPath returns the download directory of the Git repository or the chart URL, it's empty for the other chart sources
+func (s chartSpec) repoPath(cacheDir string) string {
+	if s.ChartLocalPath != "" || s.ChartRepository != "" {
+		return ""
+	}
+	return filepath.Join(cacheDir, "repos", s.Name+"-"+generateHash(s.GitRepository+s.ChartURL))
+}
+
+// chartError is the chart resolution error reported with the details, e.g. the layout of the downloaded chart source
+type chartError struct {
+	summary, detail string
+}
+
+func (e *chartError) Error() string {
+	return e.summary + ": " + e.detail
+}
+
+// chartErrorDiags converts the chart resolution error to the diagnostics keeping its details
+func chartErrorDiags(err error) diag.Diagnostics {
+	var ce *chartError
+	if errors.As(err, &ce) {
+		return diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  ce.summary,
+			Detail:   ce.detail,
+		}}
+	}
+	return diag.FromErr(err)
+}
+
+// resolveChart prepares the chart of the spec for the Helm commands and returns its path or reference:
+// the local chart is checked, the stale repository index is refreshed, the Git repository and the chart URL
+// are downloaded into the cache with the chart dependencies built
+func resolveChart(ctx context.Context, config *ProviderConfig, spec chartSpec) (chartPath string, err error) {
+	chartPath = chartReference(spec.ChartRepository, spec.ChartPath)
+
+	if spec.ChartLocalPath != "" {
+		if _, err := os.Stat(spec.ChartLocalPath); err != nil {
+			return "", fmt.Errorf("chart path '%s' is not found: %s", spec.ChartLocalPath, err)
+		}
+		return absPath(spec.ChartLocalPath), nil
+	}
+	if ociRepository(spec.ChartRepository) {
+		return chartPath, nil
+	}
+	if spec.ChartRepository != "" {
+		// The local charts directory is resolved outside of the Helm working directory
+		info, err := os.Stat(spec.ChartRepository)
+		if err == nil && info.IsDir() {
+			chartPath = absPath(chartPath)
+		}
+		// Refresh the stale index of the Helm repository, the local charts directory has no index
+		if config.IndexCacheTTL > 0 && !config.Offline && (err != nil || !info.IsDir()) {
+			indexPath := filepath.Join(config.HelmPaths.RepositoryCache, spec.ChartRepository+"-index.yaml")
+			if cacheFresh(indexPath, config.IndexCacheTTL) {
+				tflog.Debug(ctx, fmt.Sprintf("Using the fresh cached chart repository index: '%s'", indexPath))
+			} else {
+				tflog.Info(ctx, fmt.Sprintf("Updating the stale chart repository index: '%s'...", spec.ChartRepository))
+				if output, err := config.combinedOutput(config.HelmCmd("repo", "update", spec.ChartRepository)); err != nil {
+					tflog.Warn(ctx, fmt.Sprintf("Failed to update the chart repository index, the cached one is used: %s\nHelm output: %s", err, stripANSI(string(output))))
+				}
+			}
+		}
+		return chartPath, nil
+	}
+
+	gitRepository := spec.GitRepository
+	chartURL := spec.ChartURL
+	insecure := spec.Insecure
+	repoPath := spec.repoPath(config.CacheDir)
+	if chartPath, err = securePath(repoPath, spec.ChartPath); err != nil {
+		return "", fmt.Errorf("invalid 'chart_path': %s", err)
+	}
+	chartArchive := chartArchivePath(repoPath, chartURL, spec.ChartPath)
+	if chartArchive != "" {
+		chartPath = chartArchive
+	}
+
+	tflog.Debug(ctx, fmt.Sprintf("Initializing repo directory: '%s'...", repoPath))
+
+	// Remove existing repo path if it exists
+	if gitRepository != "" {
+		if _, err := os.Stat(repoPath); err == nil {
+			if err := os.RemoveAll(repoPath); err != nil {
+				return "", fmt.Errorf("failed to delete existing directory: %s", err)
+			}
+		}
+	}
+
+	// Create repo path directory
+	if err := os.MkdirAll(repoPath, os.ModePerm); err != nil {
+		return "", fmt.Errorf("failed to create the directory: %s", err)
+	}
+
+	// Clone Git repository if specified
+	if gitRepository != "" {
+		cloneArgs := gitCloneArgs(gitRepository, spec.GitReference, repoPath, spec.GitCloneDepth, spec.GitSingleBranch, insecure)
+
+		cloned := false
+		if sparsePaths := gitSparsePaths(spec.ChartPath, spec.ValuesFiles); spec.GitSparseCheckout && len(sparsePaths) > 0 {
+			tflog.Info(ctx, fmt.Sprintf("Git Repository sparse cloning: '%s' paths: %s...", gitRepository, strings.Join(sparsePaths, ", ")))
+			if err := gitSparseClone(config, cloneArgs, repoPath, sparsePaths); err != nil {
+				tflog.Warn(ctx, fmt.Sprintf("Git sparse checkout failed, falling back to the full clone: %s", err))
+				if err := os.RemoveAll(repoPath); err != nil {
+					return "", fmt.Errorf("failed to delete existing directory: %s", err)
+				}
+			} else {
+				cloned = true
+			}
+		}
+
+		if !cloned {
+			tflog.Info(ctx, fmt.Sprintf("Git Repository cloning: '%s'...", gitRepository))
+			if err := runGitCmd(config, cloneArgs...); err != nil {
+				return "", fmt.Errorf("failed to clone the Git repository: %s", err)
+			}
+		}
+
+		if spec.GitSubmodules {
+			tflog.Info(ctx, fmt.Sprintf("Git submodules initializing: '%s'...", gitRepository))
+			if err := runGitCmd(config, gitSubmoduleArgs(gitRepository, repoPath, insecure)...); err != nil {
+				return "", fmt.Errorf("failed to update the Git submodules: %s", err)
+			}
+		}
+
+		if spec.GitLFS {
+			if !gitUsesLFS(repoPath) {
+				tflog.Debug(ctx, fmt.Sprintf("Git repository doesn't use Git LFS, skipping the LFS pull: '%s'", gitRepository))
+			} else {
+				tflog.Info(ctx, fmt.Sprintf("Git LFS files pulling: '%s'...", gitRepository))
+				if err := runGitCmd(config, "-C", repoPath, "lfs", "pull"); err != nil {
+					if strings.Contains(err.Error(), "'lfs' is not a git command") {
+						return "", &chartError{
+							summary: "git-lfs binary is not found",
+							detail:  fmt.Sprintf("Git repository '%s' uses Git LFS, install 'git-lfs' next to the git binary or disable 'git_lfs'", gitRepository),
+						}
+					}
+					return "", fmt.Errorf("failed to pull the Git LFS files: %s", err)
+				}
+			}
+		}
+	}
+
+	// Download chart from URL if specified, the packaged chart without the path inside is installed as is
+	if chartURL != "" {
+		client := newGetterClient(config, chartURL, repoPath, getter.ClientModeAny, insecure)
+		if chartArchive != "" {
+			client = newGetterClient(config, chartURL, chartArchive, getter.ClientModeFile, insecure)
+			client.Decompressors = map[string]getter.Decompressor{}
+		}
+
+		tflog.Info(ctx, fmt.Sprintf("Chart URL downloading: '%s' to '%s'...", chartURL, repoPath))
+		if err := client.Get(); err != nil {
+			return "", fmt.Errorf("failed to fetch the chart URL: %s\nError: %s", redactURL(chartURL), strings.ReplaceAll(err.Error(), client.Src, redactURL(client.Src)))
+		}
+	}
+
+	// Fail early with the repo layout, since Helm error for the missing chart is unclear
+	if info, err := os.Stat(chartPath); err != nil || (chartArchive == "" && !info.IsDir()) {
+		return "", &chartError{
+			summary: fmt.Sprintf("chart path '%s' is not found", spec.ChartPath),
+			detail:  fmt.Sprintf("Expected chart directory: %s\nContents of '%s':\n%s", chartPath, repoPath, listDir(repoPath)),
+		}
+	}
+
+	// Add the dependency repositories, so Helm can authenticate to them on the dependency build
+	for _, r := range spec.DependencyRepositories {
+		repo := r.(map[string]interface{})
+		repoURL := repo["url"].(string)
+		repoAddCmd := dependencyRepoAddCmd(config, repoURL, repo["username"].(string), repo["password"].(string), insecure)
+		var repoAddStderr bytes.Buffer
+		repoAddCmd.Stderr = &repoAddStderr
+		tflog.Debug(ctx, fmt.Sprintf("Adding Helm dependency repository: '%s'...", repoURL))
+		if err := config.run(repoAddCmd); err != nil {
+			return "", fmt.Errorf("failed to add the dependency repository '%s': %s\nHelm output: %s", repoURL, err, stripANSI(repoAddStderr.String()))
+		}
+	}
+
+	// Build Helm dependency, unless it's resolved by the Helm command itself or packaged with the chart
+	if !spec.DependencyUpdateOnInstall && chartArchive == "" {
+		depCmd := config.HelmCmd("dependency", "build", chartPath)
+		depTimeout, timeoutOption := config.CommandTimeout, "provider 'command_timeout'"
+		if spec.DependencyTimeout > 0 {
+			depTimeout, timeoutOption = spec.DependencyTimeout, "'dependency_timeout'"
+		}
+		var helmDepStderr bytes.Buffer
+		depCmd.Stderr = &helmDepStderr
+		tflog.Debug(ctx, fmt.Sprintf("Building Helm dependency: '%s'...", chartPath))
+		start := time.Now()
+		if err := runCommand(depCmd, depTimeout); err != nil {
+			if depTimeout > 0 && time.Since(start) >= depTimeout {
+				return "", fmt.Errorf("'helm dependency build' of the chart '%s' timed out after %s, increase the %s for the slow dependency repositories\nHelm output: %s", chartPath, depTimeout, timeoutOption, stripANSI(helmDepStderr.String()))
+			}
+			return "", fmt.Errorf("failed to run 'helm dependency build': %s\nHelm output: %s", err, stripANSI(helmDepStderr.String()))
+		}
+	}
+
+	return chartPath, nil
+}
+
+// runGitCmd runs the git command with the provider proxy settings
+func runGitCmd(config *ProviderConfig, args ...string) error {
+	gitCmd := exec.Command(config.GitBinPath, args...)
+	if proxyEnv := config.Proxy.env(); len(proxyEnv) > 0 {
+		gitCmd.Env = append(os.Environ(), proxyEnv...)
+	}
+
+	var gitCmdStderr bytes.Buffer
+	gitCmd.Stderr = &gitCmdStderr
+	if err := config.run(gitCmd); err != nil {
+		return fmt.Errorf("%s\nCommand output: %s", err, stripANSI(gitCmdStderr.String()))
+	}
+
+	return nil
+}
+
+// gitSparseClone clones the Git repository without blobs and checks out only the given paths
+func gitSparseClone(config *ProviderConfig, cloneArgs []string, repoPath string, sparsePaths []string) error {
+	sparseCloneArgs := append([]string{cloneArgs[0], "--filter=blob:none", "--no-checkout"}, cloneArgs[1:]...)
+	if err := runGitCmd(config, sparseCloneArgs...); err != nil {
+		return fmt.Errorf("failed to clone the Git repository: %s", err)
+	}
+	if err := runGitCmd(config, append([]string{"-C", repoPath, "sparse-checkout", "set"}, sparsePaths...)...); err != nil {
+		return fmt.Errorf("failed to set the sparse checkout paths: %s", err)
+	}
+	if err := runGitCmd(config, "-C", repoPath, "checkout"); err != nil {
+		return fmt.Errorf("failed to check out the Git repository: %s", err)
+	}
+
+	return nil
+}
+
+// gitUsesLFS checks whether any '.gitattributes' of the cloned repository tracks the files with Git LFS
+func gitUsesLFS(repoPath string) bool {
+	found := false
+	filepath.WalkDir(repoPath, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || found {
+			return filepath.SkipDir
+		}
+		if entry.IsDir() && entry.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if entry.Name() == ".gitattributes" {
+			if content, err := os.ReadFile(path); err == nil && strings.Contains(string(content), "filter=lfs") {
+				found = true
+			}
+		}
+		return nil
+	})
+	return found
+}
+
+// gitCloneArgs returns the git arguments for cloning the repository reference with the given history depth, 0 is the full history
+func gitCloneArgs(gitRepository, gitReference, repoPath string, depth int, singleBranch, insecure bool) []string {
+	args := []string{"clone"}
+	if depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(depth))
+	}
+	// The shallow clone implies the single branch one
+	if singleBranch {
+		args = append(args, "--single-branch")
+	} else {
+		args = append(args, "--no-single-branch")
+	}
+	if insecure {
+		args = append(args, "-c", "http.sslVerify=false")
+	}
+	return append(args, "--branch", gitReference, gitRepository, repoPath)
+}
+
+// gitSubmoduleArgs returns the git arguments for initializing the submodules,
+// the credentials of the repository URL are reused for the submodules on the same host
+func gitSubmoduleArgs(gitRepository, repoPath string, insecure bool) []string {
+	var args []string
+	if insecure {
+		args = append(args, "-c", "http.sslVerify=false")
+	}
+	if u, err := url.Parse(gitRepository); err == nil && u.User != nil && (u.Scheme == "http" || u.Scheme == "https") {
+		withCreds := url.URL{Scheme: u.Scheme, User: u.User, Host: u.Host, Path: "/"}
+		withoutCreds := url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}
+		args = append(args, "-c", fmt.Sprintf("url.%s.insteadOf=%s", withCreds.String(), withoutCreds.String()))
+	}
+
+	return append(args, "-C", repoPath, "submodule", "update", "--init", "--recursive")
+}
+
+// gitSparsePaths returns the repository directories required for the release: the chart one and the relative values files ones,
+// nothing is returned if the whole repository is required
+func gitSparsePaths(chartPath string, valuesFiles []interface{}) []string {
+	dirs := []string{chartPath}
+	for _, v := range valuesFiles {
+		if vf := v.(string); strings.HasPrefix(vf, ".") {
+			dirs = append(dirs, filepath.Dir(vf))
+		}
+	}
+
+	var paths []string
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		dir = filepath.ToSlash(filepath.Clean(dir))
+		if dir == "." || strings.HasPrefix(dir, "..") || strings.HasPrefix(dir, "/") {
+			return nil
+		}
+		if !seen[dir] {
+			seen[dir] = true
+			paths = append(paths, dir)
+		}
+	}
+
+	return paths
+}
+
+// helmStatus represents the 'helm status -o json' output
+type helmStatus struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Version   int    `json:"version"`
+	Info      struct {
+		Status       string `json:"status"`
+		Description  string `json:"description"`
+		LastDeployed string `json:"last_deployed"`
+		Notes        string `json:"notes"`
+	} `json:"info"`
+	Chart struct {
+		Metadata struct {
+			Name       string `json:"name"`
+			Version    string `json:"version"`
+			AppVersion string `json:"appVersion"`
+		} `json:"metadata"`
+	} `json:"chart"`
+}
+
+// helmMetadata represents the 'helm get metadata -o json' output
+type helmMetadata struct {
+	Name         string `json:"name"`
+	Namespace    string `json:"namespace"`
+	Chart        string `json:"chart"`
+	Version      string `json:"version"`
+	AppVersion   string `json:"appVersion"`
+	Revision     int    `json:"revision"`
+	Status       string `json:"status"`
+	DeployedAt   string `json:"deployedAt"`
+	Dependencies []struct {
+		Name       string `json:"name"`
+		Version    string `json:"version"`
+		Repository string `json:"repository"`
+	} `json:"dependencies"`
+}
+
+// helmRelease is the Helm release information tracked by the resource
+type helmRelease struct {
+	Namespace    string
+	ChartName    string
+	ChartVersion string
+	AppVersion   string
+	Revision     string
+	Status       string
+	Description  string
+	Updated      string
+}
+
+// release converts the Helm release status to the release information stored in the state
+func (s *helmStatus) release() *helmRelease {
+	return &helmRelease{
+		Namespace:    s.Namespace,
+		ChartName:    s.Chart.Metadata.Name,
+		ChartVersion: s.Chart.Metadata.Version,
+		AppVersion:   s.Chart.Metadata.AppVersion,
+		Revision:     strconv.Itoa(s.Version),
+		Status:       s.Info.Status,
+		Description:  s.Info.Description,
+		Updated:      releaseTime(s.Info.LastDeployed),
+	}
+}
+
+// getHelmRelease retrieves the Helm release information with the single 'helm get metadata' call if Helm supports it,
+// 'helm list' is used otherwise or if the metadata doesn't have the release status (Helm < 3.15)
+func getHelmRelease(ctx context.Context, config *ProviderConfig, name, namespace string) (*helmRelease, error) {
+	if config.checkHelmVersion("helm get metadata", getMetadataHelmVersion) == nil {
+		output, err := config.output(config.HelmCmd("get", "metadata", name, "-n", namespace, "-o", "json"))
+		if err != nil {
+			tflog.Warn(ctx, fmt.Sprintf("Failed to retrieve Helm release metadata, falling back to 'helm list': %s", err))
+		} else if metadata, err := parseHelmMetadata(output); err != nil {
+			tflog.Warn(ctx, fmt.Sprintf("Falling back to 'helm list': %s", err))
+		} else if metadata.Status != "" {
+			return &helmRelease{
+				Namespace:    metadata.Namespace,
+				ChartName:    metadata.Chart,
+				ChartVersion: metadata.Version,
+				AppVersion:   metadata.AppVersion,
+				Revision:     strconv.Itoa(metadata.Revision),
+				Status:       metadata.Status,
+				Updated:      releaseTime(metadata.DeployedAt),
+			}, nil
+		}
+	}
+
+	listCmd := config.HelmCmd("list", "-n", namespace, "-f", name, "-o", "json")
+	output, err := config.output(listCmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve Helm chart information: %s", err)
+	}
+
+	var helmList []struct {
+		Name       string `json:"name"`
+		Namespace  string `json:"namespace"`
+		Revision   string `json:"revision"`
+		Updated    string `json:"updated"`
+		Status     string `json:"status"`
+		Chart      string `json:"chart"`
+		AppVersion string `json:"app_version"`
+	}
+
+	if err := json.Unmarshal(output, &helmList); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal Helm chart information: %s", err)
+	}
+
+	if len(helmList) == 0 {
+		return nil, fmt.Errorf("failed to list Helm chart: %s", name)
+	}
+
+	// The list shows the chart as '<name>-<version>'
+	helmChart := helmList[0]
+	chartParts := strings.Split(helmChart.Chart, "-")
+	return &helmRelease{
+		Namespace:    helmChart.Namespace,
+		ChartName:    strings.Join(chartParts[:len(chartParts)-1], "-"),
+		ChartVersion: chartParts[len(chartParts)-1],
+		AppVersion:   helmChart.AppVersion,
+		Revision:     helmChart.Revision,
+		Status:       helmChart.Status,
+		Updated:      releaseTime(helmChart.Updated),
+	}, nil
+}
+
+// releaseTime converts the Helm release time to RFC 3339 format, 'helm list' shows it in Go default format unlike the JSON outputs
+func releaseTime(value string) string {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999 -0700 MST"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format(time.RFC3339Nano)
+		}
+	}
+	return value
+}
+
+// parseHelmMetadata parses the 'helm get metadata -o json' output
+func parseHelmMetadata(output []byte) (*helmMetadata, error) {
+	var metadata helmMetadata
+	if err := json.Unmarshal(output, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal Helm release metadata: %s", err)
+	}
+	if metadata.Chart == "" || metadata.Version == "" {
+		return nil, fmt.Errorf("chart is missing in the Helm release metadata: %s", strings.TrimSpace(string(output)))
+	}
+	return &metadata, nil
+}
+
+// ociRepository checks whether the chart repository is the OCI registry
+func ociRepository(chartRepository string) bool {
+	return strings.HasPrefix(chartRepository, "oci://")
+}
+
+// chartReference returns the chart reference of the chart repository, the OCI reference is joined as URL to keep its scheme
+func chartReference(chartRepository, chartPath string) string {
+	if !ociRepository(chartRepository) {
+		return filepath.Join(chartRepository, chartPath)
+	}
+	if chartPath == "" {
+		return chartRepository
+	}
+	return strings.TrimSuffix(chartRepository, "/") + "/" + strings.TrimPrefix(chartPath, "/")
+}
+
+// registryLogin logs in to the OCI registry using the temporary registry config,
+// the returned function logs out and removes it, its failure is only logged so the operation error isn't masked
+func registryLogin(ctx context.Context, config *ProviderConfig, chartRepository, username, password, caFile string, insecure bool) (registryConfig string, logout func(), err error) {
+	host := strings.SplitN(strings.TrimPrefix(chartRepository, "oci://"), "/", 2)[0]
+
+	registryDir := filepath.Join(config.CacheDir, "registry")
+	if err := os.MkdirAll(registryDir, os.ModePerm); err != nil {
+		return "", nil, fmt.Errorf("failed to create the registry config directory: %s", err)
+	}
+	tmpDir, err := os.MkdirTemp(registryDir, "login-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create the registry config directory: %s", err)
+	}
+	registryConfig = filepath.Join(tmpDir, "registry.json")
+
+	loginCmd := config.HelmCmd("registry", "login", host, "--username", username, "--password-stdin", "--registry-config", registryConfig)
+	if insecure {
+		loginCmd.Args = append(loginCmd.Args, "--insecure")
+	}
+	if caFile != "" {
+		loginCmd.Args = append(loginCmd.Args, "--ca-file", caFile)
+	}
+	loginCmd.Stdin = strings.NewReader(password)
+	var loginStderr bytes.Buffer
+	loginCmd.Stderr = &loginStderr
+
+	tflog.Debug(ctx, fmt.Sprintf("Logging in to the OCI registry: '%s'...", host))
+	if err := config.run(loginCmd); err != nil {
+		os.RemoveAll(tmpDir)
+		return "", nil, fmt.Errorf("failed to log in to the OCI registry '%s': %s\nHelm output: %s", host, err, stripANSI(loginStderr.String()))
+	}
+
+	logout = func() {
+		tflog.Debug(ctx, fmt.Sprintf("Logging out of the OCI registry: '%s'...", host))
+		if output, err := config.combinedOutput(config.HelmCmd("registry", "logout", host, "--registry-config", registryConfig)); err != nil {
+			tflog.Warn(ctx, fmt.Sprintf("Failed to log out of the OCI registry '%s': %s\nHelm output: %s", host, err, stripANSI(string(output))))
+		}
+		if err := os.RemoveAll(tmpDir); err != nil {
+			tflog.Warn(ctx, fmt.Sprintf("Failed to remove the registry config: %s", err))
+		}
+	}
+	return registryConfig, logout, nil
+}
+
+// dependencyRepoAddCmd creates the 'helm repo add' command for the dependency repository, the password is passed via stdin
+func dependencyRepoAddCmd(config *ProviderConfig, repoURL, username, password string, insecure bool) *exec.Cmd {
+	cmd := config.HelmCmd("repo", "add", "terrahelm-"+generateHash(repoURL), repoURL, "--force-update")
+	if username != "" {
+		cmd.Args = append(cmd.Args, "--username", username)
+	}
+	if password != "" {
+		cmd.Args = append(cmd.Args, "--password-stdin")
+		cmd.Stdin = strings.NewReader(password)
+	}
+	if insecure {
+		cmd.Args = append(cmd.Args, "--insecure-skip-tls-verify")
+	}
+	if config.CABundleFile != "" {
+		cmd.Args = append(cmd.Args, "--ca-file", config.CABundleFile)
+	}
+	return cmd
+}
+
+// getHelmStatus retrieves the Helm release status, the latest revision is used if revision is 0
+func getHelmStatus(config *ProviderConfig, name, namespace string, revision int) (*helmStatus, error) {
+	args := []string{"status", name, "-n", namespace, "-o", "json"}
+	if revision > 0 {
+		args = append(args, "--revision", strconv.Itoa(revision))
+	}
+
+	statusCmd := config.HelmCmd(args...)
+	var statusCmdStderr bytes.Buffer
+	statusCmd.Stderr = &statusCmdStderr
+	output, err := config.output(statusCmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve Helm release status: %s\nHelm output: %s", err, stripANSI(statusCmdStderr.String()))
+	}
+
+	var status helmStatus
+	if err := json.Unmarshal(output, &status); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal Helm release status: %s", err)
+	}
+
+	return &status, nil
+}
+
+// parseHelmReleaseOutput parses the 'helm install/upgrade -o json' output
+func parseHelmReleaseOutput(output []byte) (*helmStatus, error) {
+	var release helmStatus
+	if err := json.Unmarshal(output, &release); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal Helm release output: %s", err)
+	}
+	if release.Name == "" || release.Version == 0 {
+		return nil, fmt.Errorf("release is missing in the Helm output: %s", strings.TrimSpace(string(output)))
+	}
+	return &release, nil
+}
+
+// deleteArgsFields are the uninstall flags set by the resource fields
+var deleteArgsFields = map[string]string{
+	"-n":             "namespace",
+	"--namespace":    "namespace",
+	"--keep-history": "keep_history",
+	"--description":  "uninstall_description",
+	"--dry-run":      "delete_dry_run",
+}
+
+// deleteArgField returns the resource field setting the uninstall flag of the argument
+func deleteArgField(arg string) (string, bool) {
+	field, ok := deleteArgsFields[strings.SplitN(arg, "=", 2)[0]]
+	return field, ok
+}
+
+// setLiteralArgs returns the '--set-literal' arguments of the literal values
+func setLiteralArgs(setLiteral []interface{}) []string {
+	var args []string
+	for _, v := range setLiteral {
+		value := v.(map[string]interface{})
+		args = append(args, "--set-literal", fmt.Sprintf("%s=%s", value["name"], value["value"]))
+	}
+	return args
+}
+
+// valuesFileArgs returns the values files of the Helm command arguments in the order Helm merges them
+func valuesFileArgs(args []string) []string {
+	var files []string
+	for i, arg := range args {
+		switch {
+		case (arg == "-f" || arg == "--values") && i+1 < len(args):
+			files = append(files, args[i+1])
+		case strings.HasPrefix(arg, "--values="):
+			files = append(files, strings.TrimPrefix(arg, "--values="))
+		}
+	}
+	return files
+}
+
+// hasOutputArg returns true if the output format is set in the custom arguments
+func hasOutputArg(customArgs []interface{}) bool {
+	for _, arg := range customArgs {
+		switch a := arg.(string); {
+		case a == "-o", a == "--output", strings.HasPrefix(a, "-o="), strings.HasPrefix(a, "--output="):
+			return true
+		}
+	}
+	return false
+}
+
+// waitForResources polls the given release resources until their conditions are true or the timeout is reached
+func waitForResources(ctx context.Context, config *ProviderConfig, name, namespace string, waitFor []interface{}, timeout time.Duration) error {
+	client, err := newKubeClient(config.KubeAuth, config.Proxy, config.CABundleFile)
+	if err != nil {
+		return err
+	}
+
+	type waitTarget struct {
+		desc, path, condition string
+	}
+
+	var manifest []byte
+	var targets []waitTarget
+	for _, w := range waitFor {
+		target := w.(map[string]interface{})
+		kind, resName, condition := target["kind"].(string), target["name"].(string), target["condition"].(string)
+		resNamespace, apiVersion := target["namespace"].(string), target["api_version"].(string)
+		if resNamespace == "" {
+			resNamespace = namespace
+		}
+
+		if apiVersion == "" {
+			if manifest == nil {
+				if manifest, err = config.output(config.HelmCmd("get", "manifest", name, "-n", namespace)); err != nil {
+					return fmt.Errorf("failed to retrieve Helm release manifest: %s", err)
+				}
+			}
+			if apiVersion, err = manifestAPIVersion(manifest, kind, resName); err != nil {
+				return err
+			}
+		}
+
+		resPath, err := client.resourcePath(ctx, apiVersion, kind, resNamespace, resName)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s '%s': %s", kind, resName, err)
+		}
+		targets = append(targets, waitTarget{fmt.Sprintf("%s/%s (%s)", kind, resName, condition), resPath, condition})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		var pending []string
+		for _, target := range targets {
+			ready, err := resourceConditionTrue(ctx, client, target.path, target.condition)
+			if err != nil && ctx.Err() == nil {
+				return fmt.Errorf("failed to check %s: %s", target.desc, err)
+			}
+			if !ready {
+				pending = append(pending, target.desc)
+			}
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		tflog.Debug(ctx, "Waiting for the resources: "+strings.Join(pending, ", "))
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for the resources to be ready: %s", strings.Join(pending, ", "))
+		case <-time.After(waitForPollInterval):
+		}
+	}
+}
+
+// resourceConditionTrue checks whether the status condition of the Kubernetes object is true, missing object isn't ready
+func resourceConditionTrue(ctx context.Context, client *kubeClient, resPath, condition string) (bool, error) {
+	var obj struct {
+		Status struct {
+			Conditions []struct {
+				Type   string `json:"type"`
+				Status string `json:"status"`
+			} `json:"conditions"`
+		} `json:"status"`
+	}
+
+	status, err := client.do(ctx, http.MethodGet, resPath, "", nil, &obj)
+	if err != nil || status == http.StatusNotFound {
+		return false, err
+	}
+
+	for _, c := range obj.Status.Conditions {
+		if c.Type == condition {
+			return c.Status == "True", nil
+		}
+	}
+
+	return false, nil
+}
+
+// manifestAPIVersion returns the API version of the resource from the Helm release manifest
+func manifestAPIVersion(manifest []byte, kind, name string) (string, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(manifest))
+	for {
+		var obj struct {
+			APIVersion string `yaml:"apiVersion"`
+			Kind       string `yaml:"kind"`
+			Metadata   struct {
+				Name string `yaml:"name"`
+			} `yaml:"metadata"`
+		}
+		if err := decoder.Decode(&obj); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return "", fmt.Errorf("failed to parse Helm release manifest: %s", err)
+		}
+		if obj.Kind == kind && obj.Metadata.Name == name {
+			return obj.APIVersion, nil
+		}
+	}
+
+	return "", fmt.Errorf("%s '%s' is not found in the Helm release manifest, set 'api_version' for the resources created outside of the chart", kind, name)
+}
+
+// planReleaseChanges renders the chart with the planned values and summarizes the object changes against the live release manifest
+func planReleaseChanges(ctx context.Context, config *ProviderConfig, d *schema.ResourceDiff) (string, error) {
+	name := config.releaseName(d.Get("name").(string))
+	namespace := d.Get("namespace").(string)
+	chartRepository := d.Get("chart_repository").(string)
+	chartPath := d.Get("chart_path").(string)
+	gitRepository := d.Get("git_repository").(string)
+	gitReference := d.Get("git_reference").(string)
+	chartURL := d.Get("chart_url").(string)
+	chartLocalPath := d.Get("chart_local_path").(string)
+	chartVersion := d.Get("chart_version").(string)
+	repository := config.repositoryAuth(d.Get("repository_username").(string), d.Get("repository_password").(string), d.Get("repository_ca_file").(string))
+	passCredentials := d.Get("pass_credentials").(bool)
+	values := d.Get("values").(string)
+	valuesFiles := d.Get("values_files").([]interface{})
+	dependencyUpdateOnInstall := d.Get("dependency_update_on_install").(bool)
+
+	// The plan doesn't download anything, so the chart source and the values files of the last apply are used
+	if d.HasChanges("git_repository", "git_reference", "chart_url", "values_files") {
+		return "", fmt.Errorf("the chart source or the values files are changed, they're downloaded on apply")
+	}
+
+	if repo, chart, ok := resolveChartShorthand(config, chartURL); ok {
+		chartRepository, chartPath, chartURL = repo, chart, ""
+	}
+	if resolved := d.Get("resolved_chart_version").(string); d.Get("pin_resolved_version").(bool) && chartRepository != "" && resolved != "" && !d.HasChange("chart_version") && !exactVersion(chartVersion) {
+		chartVersion = resolved
+	}
+
+	fullChartPath := chartReference(chartRepository, chartPath)
+	repoPath := ""
+	if chartLocalPath != "" {
+		fullChartPath = absPath(chartLocalPath)
+	} else if dirExists(chartRepository) {
+		fullChartPath = absPath(fullChartPath)
+	} else if chartRepository == "" {
+		repoPath = filepath.Join(config.CacheDir, "repos", name+"-"+generateHash(gitRepository+chartURL))
+		var err error
+		if fullChartPath, err = securePath(repoPath, chartPath); err != nil {
+			return "", fmt.Errorf("invalid 'chart_path': %s", err)
+		}
+		if chartArchive := chartArchivePath(repoPath, chartURL, chartPath); chartArchive != "" {
+			fullChartPath = chartArchive
+		}
+		if _, err := os.Stat(fullChartPath); err != nil {
+			return "", fmt.Errorf("chart source isn't downloaded yet: %s", fullChartPath)
+		}
+	}
+
+	helmCmd := config.HelmCmd("template", name, fullChartPath, "--namespace", namespace, "--is-upgrade")
+	if workDir := releaseWorkDir(config.CacheDir, name, repoPath, chartLocalPath); dirExists(workDir) {
+		helmCmd.Dir = workDir
+	}
+
+	valuesPath := filepath.Join(config.CacheDir, "values", name)
+	if gitReference != "" {
+		valuesPath = filepath.Join(valuesPath, gitReference)
+	} else if chartRepository != "" {
+		valuesPath = filepath.Join(valuesPath, chartRepository)
+	}
+	for _, v := range valuesFiles {
+		vf := v.(string)
+		vfPath := filepath.Join(valuesPath, fmt.Sprintf("%s-%s-values.yaml", name, generateHash(vf)))
+		if strings.HasPrefix(vf, ".") && repoPath != "" {
+			var err error
+			if vfPath, err = securePath(repoPath, vf); err != nil {
+				return "", fmt.Errorf("invalid values file '%s': %s", vf, err)
+			}
+		}
+		if _, err := os.Stat(vfPath); err != nil {
+			return "", fmt.Errorf("values file '%s' isn't downloaded yet", redactURL(vf))
+		}
+		helmCmd.Args = append(helmCmd.Args, "-f", vfPath)
+	}
+	if valuesFrom := d.Get("values_from").([]interface{}); len(valuesFrom) > 0 {
+		vfPaths, cleanup, err := writeValuesFrom(ctx, config, valuesFrom, name, namespace)
+		if err != nil {
+			return "", err
+		}
+		defer cleanup()
+		for _, vfPath := range vfPaths {
+			helmCmd.Args = append(helmCmd.Args, "-f", vfPath)
+		}
+	}
+	if values != "" {
+		planValuesPath := filepath.Join(config.CacheDir, "values", name, "plan")
+		if err := os.MkdirAll(planValuesPath, os.ModePerm); err != nil {
+			return "", fmt.Errorf("failed to create the directory for values: %s", err)
+		}
+		valuesFilePath := filepath.Join(planValuesPath, fmt.Sprintf("%s-%s-values.yaml", name, generateHash(values)))
+		if err := os.WriteFile(valuesFilePath, []byte(values), 0600); err != nil {
+			return "", fmt.Errorf("failed to create Helm values file: %s", err)
+		}
+		helmCmd.Args = append(helmCmd.Args, "-f", valuesFilePath)
+	}
+	if setLiteral := d.Get("set_literal").([]interface{}); len(setLiteral) > 0 {
+		if err := config.checkHelmVersion("set_literal", setLiteralHelmVersion); err != nil {
+			return "", err
+		}
+		helmCmd.Args = append(helmCmd.Args, setLiteralArgs(setLiteral)...)
+	}
+
+	if dependencyUpdateOnInstall {
+		helmCmd.Args = append(helmCmd.Args, "--dependency-update")
+	}
+	if chartVersion != "" {
+		helmCmd.Args = append(helmCmd.Args, "--version", chartVersion)
+	}
+	if d.Get("insecure").(bool) && ociRepository(chartRepository) {
+		helmCmd.Args = append(helmCmd.Args, "--insecure-skip-tls-verify")
+	}
+	// Log in to the registry for the render only, so the password isn't passed as the command argument
+	if ociRepository(chartRepository) && chartLocalPath == "" && repository.Username != "" {
+		registryConfig, logout, err := registryLogin(ctx, config, chartRepository, repository.Username, repository.Password, repository.CAFile, d.Get("insecure").(bool))
+		if err != nil {
+			return "", err
+		}
+		defer logout()
+		helmCmd.Args = append(helmCmd.Args, "--registry-config", registryConfig)
+	} else {
+		if repository.Username != "" {
+			helmCmd.Args = append(helmCmd.Args, "--username", repository.Username)
+		}
+		if repository.Password != "" {
+			helmCmd.Args = append(helmCmd.Args, "--password", repository.Password)
+		}
+	}
+	if passCredentials {
+		helmCmd.Args = append(helmCmd.Args, "--pass-credentials")
+	}
+	if repository.CAFile != "" {
+		helmCmd.Args = append(helmCmd.Args, "--ca-file", repository.CAFile)
+	}
+
+	var helmCmdStderr bytes.Buffer
+	helmCmd.Stderr = &helmCmdStderr
+	tflog.Debug(ctx, fmt.Sprintf("Rendering the planned Helm chart: %s", redactArgs(helmCmd.Args)))
+	planned, err := config.output(helmCmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to render the Helm chart: %s\nHelm output: %s", err, stripANSI(helmCmdStderr.String()))
+	}
+
+	manifestCmd := config.HelmCmd("get", "manifest", "-n", namespace, name)
+	live, err := config.output(manifestCmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve Helm release manifest: %s", err)
+	}
+
+	return diffManifests(live, planned, namespace)
+}
+
+// diffManifests summarizes the added, removed and changed objects between the manifests, sorted by the object
+func diffManifests(live, planned []byte, namespace string) (string, error) {
+	liveObjects, err := manifestObjects(live, namespace)
+	if err != nil {
+		return "", err
+	}
+	plannedObjects, err := manifestObjects(planned, namespace)
+	if err != nil {
+		return "", err
+	}
+
+	var keys []string
+	for key := range liveObjects {
+		keys = append(keys, key)
+	}
+	for key := range plannedObjects {
+		if _, ok := liveObjects[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var changes []string
+	for _, key := range keys {
+		liveObject, inLive := liveObjects[key]
+		plannedObject, inPlanned := plannedObjects[key]
+		switch {
+		case !inLive:
+			changes = append(changes, "+ "+key)
+		case !inPlanned:
+			changes = append(changes, "- "+key)
+		case liveObject != plannedObject:
+			changes = append(changes, "~ "+key)
+		}
+	}
+	if len(changes) == 0 {
+		return "No Kubernetes object changes", nil
+	}
+
+	return strings.Join(changes, "\n"), nil
+}
+
+// manifestObjects returns the normalized manifest objects by the 'Kind namespace/name' key, the namespace defaults to the release one.
+// The hooks are skipped, since 'helm template' renders them but the release manifest doesn't have them
+func manifestObjects(manifest []byte, namespace string) (map[string]string, error) {
+	objects := make(map[string]string)
+	decoder := yaml.NewDecoder(bytes.NewReader(manifest))
+	for {
+		var obj map[string]interface{}
+		if err := decoder.Decode(&obj); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse Helm manifest: %s", err)
+		}
+		kind, _ := obj["kind"].(string)
+		if kind == "" {
+			continue
+		}
+
+		metadata, _ := obj["metadata"].(map[string]interface{})
+		if annotations, _ := metadata["annotations"].(map[string]interface{}); annotations["helm.sh/hook"] != nil {
+			continue
+		}
+		objName, _ := metadata["name"].(string)
+		objNamespace, _ := metadata["namespace"].(string)
+		if objNamespace == "" {
+			objNamespace = namespace
+		}
+
+		normalized, err := yaml.Marshal(obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to normalize Helm manifest: %s", err)
+		}
+		objects[fmt.Sprintf("%s %s/%s", kind, objNamespace, objName)] = string(normalized)
+	}
+
+	return objects, nil
+}
+
+// releaseHooksSchema returns the computed schema of the Helm release hooks
+func releaseHooksSchema() *schema.Schema {
+	return &schema.Schema{
+		Description: "The hooks of the installed Helm release",
+		Type:        schema.TypeList,
+		Computed:    true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"name": {
+					Description: "The name of the hook resource",
+					Type:        schema.TypeString,
+					Computed:    true,
+				},
+				"kind": {
+					Description: "The kind of the hook resource",
+					Type:        schema.TypeString,
+					Computed:    true,
+				},
+				"events": {
+					Description: "The events the hook is executed on, e.g. pre-install",
+					Type:        schema.TypeList,
+					Computed:    true,
+					Elem:        &schema.Schema{Type: schema.TypeString},
+				},
+			},
+		},
+	}
+}
+
+// getHelmHooks retrieves the Helm release hooks, the latest revision is used if revision is 0
+func getHelmHooks(config *ProviderConfig, name, namespace string, revision int) ([]interface{}, error) {
+	args := []string{"get", "hooks", "-n", namespace, name}
+	if revision > 0 {
+		args = append(args, "--revision", strconv.Itoa(revision))
+	}
+
+	output, err := config.output(config.HelmCmd(args...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve Helm release hooks: %s", err)
+	}
+
+	return parseHelmHooks(output)
+}
+
+// configHashAttributes are the install or upgrade inputs hashed into 'config_hash' besides the values and the chart version
+var configHashAttributes = []string{
+	"atomic", "atomic_on_install", "chart_local_path", "chart_path", "chart_repository", "chart_url", "chart_version",
+	"create_namespace", "custom_args", "dependency_update_on_install", "git_reference", "git_repository", "install_args", "name", "namespace",
+	"post_renderer", "post_renderer_url", "recreate_triggers", "replace", "set_literal", "take_ownership", "upgrade_args",
+	"values_files", "values_from", "verify", "wait", "wait_on_install", "wait_on_upgrade",
+}
+
+// configHash returns the SHA-256 hex hash of the applied inputs, the values are canonicalized so the equivalent ones are hashed equally
+func configHash(d *schema.ResourceData, chartVersion string) (string, error) {
+	inputs := map[string]interface{}{}
+	for _, key := range configHashAttributes {
+		inputs[key] = d.Get(key)
+	}
+	// The pinned version is installed instead of the requested one
+	inputs["chart_version"] = chartVersion
+
+	values, err := sanitizeYAMLString(d.Get("values").(string))
+	if err != nil {
+		return "", err
+	}
+	inputs["values"] = values
+
+	// The map keys are sorted by the JSON encoder
+	data, err := json.Marshal(inputs)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal the Helm release config: %s", err)
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// getManifestHash returns the SHA-256 hex hash of the Helm release manifest
+func getManifestHash(config *ProviderConfig, name, namespace string) (string, error) {
+	output, err := config.output(config.HelmCmd("get", "manifest", name, "-n", namespace))
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve Helm release manifest: %s", err)
+	}
+
+	hash := sha256.Sum256(output)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// getHelmNotes retrieves the Helm release notes without the 'NOTES:' header, the latest revision is used if revision is 0
+func getHelmNotes(config *ProviderConfig, name, namespace string, revision int) (string, error) {
+	args := []string{"get", "notes", "-n", namespace, name}
+	if revision > 0 {
+		args = append(args, "--revision", strconv.Itoa(revision))
+	}
+
+	output, err := config.output(config.HelmCmd(args...))
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve Helm release notes: %s", err)
+	}
+
+	return strings.TrimSpace(strings.TrimPrefix(string(output), "NOTES:")), nil
+}
+
+// parseHelmHooks parses the 'helm get hooks' manifests, the events are taken from the 'helm.sh/hook' annotation
+func parseHelmHooks(manifest []byte) ([]interface{}, error) {
+	hooks := []interface{}{}
+	decoder := yaml.NewDecoder(bytes.NewReader(manifest))
+	for {
+		var obj struct {
+			Kind     string `yaml:"kind"`
+			Metadata struct {
+				Name        string            `yaml:"name"`
+				Annotations map[string]string `yaml:"annotations"`
+			} `yaml:"metadata"`
+		}
+		if err := decoder.Decode(&obj); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse Helm release hooks: %s", err)
+		}
+		if obj.Kind == "" {
+			continue
+		}
+
+		var events []interface{}
+		for _, event := range strings.Split(obj.Metadata.Annotations["helm.sh/hook"], ",") {
+			if event = strings.TrimSpace(event); event != "" {
+				events = append(events, event)
+			}
+		}
+		hooks = append(hooks, map[string]interface{}{
+			"name":   obj.Metadata.Name,
+			"kind":   obj.Kind,
+			"events": events,
+		})
+	}
+
+	return hooks, nil
+}
+
+// chartMetadata represents the Chart.yaml fields exposed in the state
+type chartMetadata struct {
+	Name        string `yaml:"name"`
+	Version     string `yaml:"version"`
+	AppVersion  string `yaml:"appVersion"`
+	Description string `yaml:"description"`
+	Type        string `yaml:"type"`
+}
+
+func (c *chartMetadata) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"name":        c.Name,
+		"version":     c.Version,
+		"app_version": c.AppVersion,
+		"description": c.Description,
+		"type":        c.Type,
+	}
+}
+
+// parseChartMetadata parses the Chart.yaml content, the chart type defaults to application like in Helm
+func parseChartMetadata(data []byte) (*chartMetadata, error) {
+	var metadata chartMetadata
+	if err := yaml.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse Chart.yaml: %s", err)
+	}
+	if metadata.Type == "" {
+		metadata.Type = "application"
+	}
+
+	return &metadata, nil
+}
+
+// getChartMetadata reads Chart.yaml of the chart directory, 'helm show chart' is used for the packaged and repository charts
+func getChartMetadata(config *ProviderConfig, chartPath string, showArgs ...string) (*chartMetadata, error) {
+	if info, err := os.Stat(chartPath); err == nil && info.IsDir() {
+		data, err := os.ReadFile(filepath.Join(chartPath, "Chart.yaml"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Chart.yaml: %s", err)
 		}
-		return diag.FromErr(fmt.Errorf(errMsg))
+		return parseChartMetadata(data)
 	}
 
-	// Set the ID for the resource
-	d.SetId(fmt.Sprintf("%s/%s", namespace, name))
+	showCmd := config.HelmCmd(append([]string{"show", "chart", chartPath}, showArgs...)...)
+	var showCmdStderr bytes.Buffer
+	showCmd.Stderr = &showCmdStderr
+	output, err := config.output(showCmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run 'helm show chart': %s\nHelm output: %s", err, stripANSI(showCmdStderr.String()))
+	}
 
-	log.Printf("Helm chart %s has been %s(ed) successfully. Helm output:\n%s", name, cmd, helmCmdStdout.String())
+	return parseChartMetadata(output)
+}
 
-	// Read the release status to update the Terraform state
-	return resourceHelmReleaseRead(ctx, d, m)
+// checkChartProvenance ensures the local chart can be verified, remote charts are verified by Helm itself
+func checkChartProvenance(chartPath string) error {
+	info, err := os.Stat(chartPath)
+	if err != nil {
+		return nil
+	}
+
+	if info.IsDir() {
+		return fmt.Errorf("failed to verify the chart '%s': only packaged charts can be verified", chartPath)
+	}
+	if _, err := os.Stat(chartPath + ".prov"); err != nil {
+		return fmt.Errorf("failed to verify the chart '%s': provenance file '%s.prov' is missing", chartPath, chartPath)
+	}
+
+	return nil
+}
+
+// checkNamespaceExists checks the namespace existence using the Kubernetes API
+func checkNamespaceExists(ctx context.Context, config *ProviderConfig, namespace string) error {
+	client, err := newKubeClient(config.KubeAuth, config.Proxy, config.CABundleFile)
+	if err != nil {
+		return err
+	}
+
+	status, err := client.do(ctx, http.MethodGet, "/api/v1/namespaces/"+url.PathEscape(namespace), "", nil, nil)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusNotFound {
+		return fmt.Errorf("namespace '%s' doesn't exist, create it or set 'create_namespace'", namespace)
+	}
+
+	return nil
+}
+
+// writeValuesFrom fetches the 'values_from' sources into the temporary values files, the returned function removes them
+func writeValuesFrom(ctx context.Context, config *ProviderConfig, valuesFrom []interface{}, name, namespace string) (vfPaths []string, cleanup func(), err error) {
+	valuesPath := filepath.Join(config.CacheDir, "values")
+	if err := os.MkdirAll(valuesPath, os.ModePerm); err != nil {
+		return nil, nil, fmt.Errorf("failed to create the directory for values: %s", err)
+	}
+	valuesFromPath, err := os.MkdirTemp(valuesPath, name+"-from-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create the directory for values: %s", err)
+	}
+	cleanup = func() {
+		os.RemoveAll(valuesFromPath)
+	}
+
+	for i, v := range valuesFrom {
+		source := v.(map[string]interface{})
+		kind, sourceName, key := source["kind"].(string), source["name"].(string), source["key"].(string)
+		sourceNamespace := source["namespace"].(string)
+		if sourceNamespace == "" {
+			sourceNamespace = namespace
+		}
+
+		tflog.Info(ctx, fmt.Sprintf("Values fetching: %s '%s/%s' key '%s'...", kind, sourceNamespace, sourceName, key))
+		data, err := getValuesFrom(ctx, config, kind, sourceNamespace, sourceName, key)
+		if err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("failed to fetch 'values_from': %s", err)
+		}
+
+		vfPath := filepath.Join(valuesFromPath, fmt.Sprintf("%d-values.yaml", i))
+		if err := os.WriteFile(vfPath, data, 0600); err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("failed to create Helm values file: %s", err)
+		}
+		vfPaths = append(vfPaths, vfPath)
+	}
+
+	return vfPaths, cleanup, nil
+}
+
+// getValuesFrom fetches the values YAML of the ConfigMap or Secret key using the Kubernetes API
+func getValuesFrom(ctx context.Context, config *ProviderConfig, kind, namespace, name, key string) ([]byte, error) {
+	client, err := newKubeClient(config.KubeAuth, config.Proxy, config.CABundleFile)
+	if err != nil {
+		return nil, err
+	}
+
+	resource := "configmaps"
+	if kind == "Secret" {
+		resource = "secrets"
+	}
+
+	var object struct {
+		Data map[string]string `json:"data"`
+	}
+	status, err := client.do(ctx, http.MethodGet, "/api/v1/namespaces/"+url.PathEscape(namespace)+"/"+resource+"/"+url.PathEscape(name), "", nil, &object)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		return nil, fmt.Errorf("%s '%s/%s' is not found", kind, namespace, name)
+	}
+
+	value, ok := object.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("key '%s' is not found in %s '%s/%s'", key, kind, namespace, name)
+	}
+
+	// The Secret data is base64 encoded
+	if kind == "Secret" {
+		data, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode key '%s' of %s '%s/%s': %s", key, kind, namespace, name, err)
+		}
+		return data, nil
+	}
+
+	return []byte(value), nil
 }
 
+// applyNamespaceMetadata creates the namespace with the given labels and annotations or patches the existing one
+func applyNamespaceMetadata(ctx context.Context, config *ProviderConfig, namespace string, labels, annotations map[string]interface{}) error {
+	client, err := newKubeClient(config.KubeAuth, config.Proxy, config.CABundleFile)
+	if err != nil {
+		return err
+	}
+
+	metadata := map[string]interface{}{
+		"name":        namespace,
+		"labels":      labels,
+		"annotations": annotations,
+	}
+
+	nsPath := "/api/v1/namespaces/" + url.PathEscape(namespace)
+	status, err := client.do(ctx, http.MethodGet, nsPath, "", nil, nil)
+	if err != nil {
+		return err
+	}
+
+	if status == http.StatusNotFound {
+		ns := map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Namespace",
+			"metadata":   metadata,
+		}
+		_, err = client.do(ctx, http.MethodPost, "/api/v1/namespaces", "application/json", ns, nil)
+		return err
+	}
+
+	patch := map[string]interface{}{
+		"metadata": metadata,
+	}
+	_, err = client.do(ctx, http.MethodPatch, nsPath, "application/merge-patch+json", patch, nil)
+	return err
+}
+
+// sanitizeYAMLString returns the canonical form of the YAML values, so the equivalent values are serialized identically
+// in the state and in the cluster: the map keys are sorted and converted to strings as Helm does
 func sanitizeYAMLString(yamlString string) (string, error) {
 	if strings.TrimSpace(yamlString) == "" {
 		return "", nil
@@ -598,7 +3325,7 @@ func sanitizeYAMLString(yamlString string) (string, error) {
 		return "", fmt.Errorf("failed to parse YAML: %w", err)
 	}
 
-	output, err := yaml.Marshal(parsedYAML)
+	output, err := yaml.Marshal(canonicalYAMLValue(parsedYAML))
 	if err != nil {
 		return "", fmt.Errorf("failed to re-serialize YAML: %w", err)
 	}
@@ -606,6 +3333,182 @@ func sanitizeYAMLString(yamlString string) (string, error) {
 	return string(output), nil
 }
 
+// canonicalYAMLValue converts the non-string map keys to strings recursively, the encoder sorts the keys then
+func canonicalYAMLValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = canonicalYAMLValue(item)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = canonicalYAMLValue(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range v {
+			v[i] = canonicalYAMLValue(item)
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+// valuesDrifted reports whether the actual release values differ from the desired ones.
+// When values files are in use the cluster values are a merge of all sources, so the
+// desired values only have to be contained in them.
+func valuesDrifted(desired, actual string, partial bool) (bool, error) {
+	var desiredValues, actualValues interface{}
+	if err := yaml.Unmarshal([]byte(desired), &desiredValues); err != nil {
+		return false, fmt.Errorf("failed to parse desired values: %w", err)
+	}
+	if err := yaml.Unmarshal([]byte(actual), &actualValues); err != nil {
+		return false, fmt.Errorf("failed to parse actual values: %w", err)
+	}
+
+	if partial {
+		return !valuesContained(desiredValues, actualValues), nil
+	}
+	return !reflect.DeepEqual(desiredValues, actualValues), nil
+}
+
+// valuesContained checks that every key of the subset is present in the superset with the same value
+func valuesContained(subset, superset interface{}) bool {
+	subMap, ok := subset.(map[string]interface{})
+	if !ok {
+		return subset == nil || reflect.DeepEqual(subset, superset)
+	}
+
+	superMap, ok := superset.(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	for key, value := range subMap {
+		superValue, ok := superMap[key]
+		if !ok || !valuesContained(value, superValue) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ignoreValues replaces the ignored key paths of the actual values with the desired ones,
+// the key is removed from the actual values if it isn't desired
+func ignoreValues(desired, actual string, paths []interface{}) (string, error) {
+	if len(paths) == 0 {
+		return actual, nil
+	}
+
+	var desiredValues, actualValues map[string]interface{}
+	if err := yaml.Unmarshal([]byte(desired), &desiredValues); err != nil {
+		return "", fmt.Errorf("failed to parse desired values: %w", err)
+	}
+	if err := yaml.Unmarshal([]byte(actual), &actualValues); err != nil {
+		return "", fmt.Errorf("failed to parse actual values: %w", err)
+	}
+	if actualValues == nil {
+		actualValues = map[string]interface{}{}
+	}
+
+	for _, path := range paths {
+		keys, err := splitValuesPath(path.(string))
+		if err != nil {
+			return "", err
+		}
+		if value, ok := lookupValuesPath(desiredValues, keys); ok {
+			setValuesPath(actualValues, keys, value)
+		} else {
+			deleteValuesPath(actualValues, keys)
+		}
+	}
+
+	if len(actualValues) == 0 {
+		return "", nil
+	}
+	output, err := yaml.Marshal(canonicalYAMLValue(actualValues))
+	if err != nil {
+		return "", fmt.Errorf("failed to re-serialize YAML: %w", err)
+	}
+	return string(output), nil
+}
+
+// splitValuesPath splits the dotted key path, the dot escaped with '\' is a part of the key
+func splitValuesPath(path string) ([]string, error) {
+	var keys []string
+	var key strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '\\' && i+1 < len(path) && path[i+1] == '.':
+			key.WriteByte('.')
+			i++
+		case path[i] == '.':
+			keys = append(keys, key.String())
+			key.Reset()
+		default:
+			key.WriteByte(path[i])
+		}
+	}
+	keys = append(keys, key.String())
+
+	for _, key := range keys {
+		if key == "" {
+			return nil, fmt.Errorf("'%s' has an empty key, expected the dotted key path, e.g. 'podAnnotations.checksum'", path)
+		}
+	}
+	return keys, nil
+}
+
+// lookupValuesPath returns the value at the key path
+func lookupValuesPath(values map[string]interface{}, keys []string) (interface{}, bool) {
+	for i, key := range keys {
+		value, ok := values[key]
+		if !ok {
+			return nil, false
+		}
+		if i == len(keys)-1 {
+			return value, true
+		}
+		if values, ok = value.(map[string]interface{}); !ok {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// setValuesPath sets the value at the key path, creating the missing maps
+func setValuesPath(values map[string]interface{}, keys []string, value interface{}) {
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := values[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			values[key] = next
+		}
+		values = next
+	}
+	values[keys[len(keys)-1]] = value
+}
+
+// deleteValuesPath removes the value at the key path and the maps it leaves empty
+func deleteValuesPath(values map[string]interface{}, keys []string) {
+	if len(keys) == 1 {
+		delete(values, keys[0])
+		return
+	}
+	next, ok := values[keys[0]].(map[string]interface{})
+	if !ok {
+		return
+	}
+	deleteValuesPath(next, keys[1:])
+	if len(next) == 0 {
+		delete(values, keys[0])
+	}
+}
+
 func jsonMapToStringMap(rawValues map[string]interface{}) (map[string]string, error) {
 	converted := make(map[string]string)
 
@@ -636,9 +3539,334 @@ func jsonMapToStringMap(rawValues map[string]interface{}) (map[string]string, er
 	return converted, nil
 }
 
+// helmReleaseName returns the Helm release name of the resource with the provider prefix and suffix,
+// the data source reads the release by its full name
+func helmReleaseName(d *schema.ResourceData, config *ProviderConfig) string {
+	name := d.Get("name").(string)
+	// the data source schema doesn't have the chart attributes
+	if _, resource := d.Get("chart_path").(string); !resource {
+		return name
+	}
+	return config.releaseName(name)
+}
+
+// validateReleaseName checks the release name against Helm naming rules
+func validateReleaseName(name string) error {
+	if len(name) > releaseNameMaxLen {
+		return fmt.Errorf("release name %q exceeds the max length of %d characters", name, releaseNameMaxLen)
+	}
+	if !releaseNameRegexp.MatchString(name) {
+		return fmt.Errorf("release name %q is invalid, it must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character", name)
+	}
+	return nil
+}
+
+// namespaceNotFound checks whether the Helm command failed because the release namespace doesn't exist
+func namespaceNotFound(stderr, namespace string) bool {
+	return strings.Contains(stderr, fmt.Sprintf(`namespaces "%s" not found`, namespace))
+}
+
+// releaseNotFound checks whether the Helm status failed because the release doesn't exist, not because of the missing namespace, context or the other resource
+func releaseNotFound(err error) bool {
+	return strings.Contains(err.Error(), "release: not found")
+}
+
+// resolveChartShorthand resolves the 'repo/chart' chart URL, the repository must be added to the Helm repositories config
+func resolveChartShorthand(config *ProviderConfig, chartURL string) (repo, chart string, ok bool) {
+	if !chartShorthandRegexp.MatchString(chartURL) {
+		return "", "", false
+	}
+
+	data, err := os.ReadFile(config.HelmPaths.RepositoryConfig)
+	if err != nil {
+		return "", "", false
+	}
+	var repositories struct {
+		Repositories []struct {
+			Name string `yaml:"name"`
+		} `yaml:"repositories"`
+	}
+	if err := yaml.Unmarshal(data, &repositories); err != nil {
+		return "", "", false
+	}
+
+	repo, chart, _ = strings.Cut(chartURL, "/")
+	for _, r := range repositories.Repositories {
+		if r.Name == repo {
+			return repo, chart, true
+		}
+	}
+	return "", "", false
+}
+
+// operationInProgress checks whether the Helm command failed because another operation on the release isn't finished yet
+func operationInProgress(stderr string) bool {
+	return strings.Contains(stderr, "another operation (install/upgrade/rollback) is in progress")
+}
+
+// waitForPendingOperation polls the release status with the exponential backoff until it leaves the pending state
+func waitForPendingOperation(ctx context.Context, config *ProviderConfig, name, namespace string) error {
+	delay := operationRetryDelay
+	for poll := 1; poll <= operationPollAttempts; poll++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		status, err := getHelmStatus(config, name, namespace, 0)
+		if err != nil {
+			// The release is removed if the rollback of its first installation is finished
+			if strings.Contains(err.Error(), "not found") {
+				return nil
+			}
+			return err
+		}
+		if !strings.HasPrefix(status.Info.Status, "pending-") {
+			return nil
+		}
+		tflog.Debug(ctx, fmt.Sprintf("Helm release '%s' is still in the '%s' state, polling again in %s...", name, status.Info.Status, delay))
+
+		delay *= 2
+		if delay > operationRetryMaxDelay {
+			delay = operationRetryMaxDelay
+		}
+	}
+	return fmt.Errorf("release is still pending after %d status checks", operationPollAttempts)
+}
+
+// waitForDeployed polls the Helm release status until it's deployed or the timeout is reached
+func waitForDeployed(ctx context.Context, config *ProviderConfig, name, namespace string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		status, err := getHelmStatus(config, name, namespace, 0)
+		if err != nil && ctx.Err() == nil {
+			return fmt.Errorf("failed to check the Helm release '%s' status: %s", name, err)
+		}
+		if err == nil {
+			switch status.Info.Status {
+			case "deployed":
+				return nil
+			case "failed":
+				return fmt.Errorf("helm release '%s' has failed, it isn't deployed within 'readiness_timeout'", name)
+			}
+			tflog.Debug(ctx, fmt.Sprintf("Helm release '%s' is in the '%s' state, polling again in %s...", name, status.Info.Status, readinessPollInterval))
+		}
+
+		select {
+		case <-ctx.Done():
+			current := "unknown"
+			if status != nil {
+				current = status.Info.Status
+			}
+			return fmt.Errorf("timed out waiting for the Helm release '%s' to be deployed within %s, the status: %s", name, timeout, current)
+		case <-time.After(readinessPollInterval):
+		}
+	}
+}
+
+// cloneCmd returns a new command with the same path, arguments, environment and directory
+func cloneCmd(cmd *exec.Cmd) *exec.Cmd {
+	clone := exec.Command(cmd.Path, cmd.Args[1:]...)
+	clone.Env = cmd.Env
+	clone.Dir = cmd.Dir
+	return clone
+}
+
+// packagedChartURL checks whether the chart URL points to the packaged chart and returns its go-getter subdirectory
+func packagedChartURL(chartURL string) (subdir string, packaged bool) {
+	src, subdir := getter.SourceDirSubdir(chartURL)
+	src = strings.SplitN(src, "?", 2)[0]
+	return subdir, strings.HasSuffix(src, ".tgz") || strings.HasSuffix(src, ".tar.gz")
+}
+
+// chartArchivePath returns the download path of the packaged chart installed without extraction,
+// it's empty when the chart is extracted since the path inside the package is set by 'chart_path' or the URL subdirectory
+func chartArchivePath(repoPath, chartURL, chartPath string) string {
+	if subdir, packaged := packagedChartURL(chartURL); !packaged || subdir != "" || chartPath != "" {
+		return ""
+	}
+	src, _ := getter.SourceDirSubdir(chartURL)
+	return filepath.Join(repoPath, path.Base(strings.SplitN(src, "?", 2)[0]))
+}
+
+// exactVersion checks whether the chart version is the exact version rather than a constraint
+func exactVersion(chartVersion string) bool {
+	_, err := version.NewVersion(chartVersion)
+	return err == nil
+}
+
+// releaseStatusUnhealthy checks whether the Helm release status requires remediation
+func releaseStatusUnhealthy(status string) bool {
+	return status == "failed" || strings.HasPrefix(status, "pending-")
+}
+
+// normalizeTimeout converts the timeout to Helm duration format, plain numbers are treated as seconds
+func normalizeTimeout(timeout string) string {
+	if _, err := strconv.Atoi(timeout); err == nil {
+		return timeout + "s"
+	}
+	return timeout
+}
+
+// redactURL replaces the password and the sensitive getter parameters of the URL, the forced getter prefix is kept
+func redactURL(rawURL string) string {
+	forced := ""
+	if i := strings.Index(rawURL, "::"); i >= 0 {
+		forced, rawURL = rawURL[:i+2], rawURL[i+2:]
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return forced + rawURL
+	}
+	query := u.Query()
+	for param := range query {
+		if sensitiveURLParams[param] {
+			query.Set(param, "xxxxx")
+			u.RawQuery = query.Encode()
+		}
+	}
+
+	return forced + u.Redacted()
+}
+
+// Failure classes of the Helm commands, they're stable, so CI can branch on them
+const (
+	failureHelmError       = "helm_error"
+	failureUnexpectedExit  = "unexpected_exit"
+	failureTimeoutOrCancel = "timeout_or_cancel"
+	failureExecError       = "exec_error"
+)
+
+// helmExitStatus returns the exit code of the failed command and the failure class distinguishing the Helm errors from the infrastructure ones.
+// The code is -1 if the command is killed by the signal, e.g. on the command timeout, or it isn't run at all
+func helmExitStatus(err error) (code int, class string) {
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0, ""
+	case !errors.As(err, &exitErr):
+		return -1, failureExecError
+	case exitErr.ExitCode() == -1:
+		return -1, failureTimeoutOrCancel
+	case exitErr.ExitCode() == 1:
+		return 1, failureHelmError
+	default:
+		return exitErr.ExitCode(), failureUnexpectedExit
+	}
+}
+
+// exitStatusMessage formats the exit code and the failure class of the failed command for the diagnostics
+func exitStatusMessage(err error) string {
+	code, class := helmExitStatus(err)
+	return fmt.Sprintf("Exit code: %d, failure class: %s", code, class)
+}
+
+// stripANSI removes the ANSI escape sequences, e.g. the colors, from the command output
+func stripANSI(output string) string {
+	return ansiRegexp.ReplaceAllString(output, "")
+}
+
+// redactArgs joins the command arguments masking the sensitive values
+func redactArgs(args []string) string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		if i > 0 && sensitiveArgs[args[i-1]] {
+			arg = "***"
+		}
+		redacted[i] = arg
+	}
+	return strings.Join(redacted, " ")
+}
+
+// releaseWorkDir returns the working directory of the release Helm commands: the downloaded chart source,
+// the local chart directory or the release directory in the cache for the repository charts
+func releaseWorkDir(cacheDir, name, repoPath, chartLocalPath string) string {
+	if repoPath != "" {
+		return repoPath
+	}
+	if chartLocalPath != "" {
+		if dirExists(chartLocalPath) {
+			return absPath(chartLocalPath)
+		}
+		return filepath.Dir(absPath(chartLocalPath))
+	}
+	return filepath.Join(cacheDir, "values", name)
+}
+
+// dirExists reports whether the path is an existing directory
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// absPath returns the absolute path, so it's resolved the same in the Helm working directory. The empty path is kept
+func absPath(p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return p
+}
+
+// securePath joins the relative path to the base directory and ensures the result doesn't escape it
+func securePath(baseDir, relPath string) (string, error) {
+	fullPath := filepath.Join(baseDir, relPath)
+
+	rel, err := filepath.Rel(baseDir, fullPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path '%s': %w", relPath, err)
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path '%s' is outside of the directory '%s'", relPath, baseDir)
+	}
+
+	return fullPath, nil
+}
+
+// listDir returns the directory entries one per line for the diagnostics, the directories end with a slash
+func listDir(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Sprintf("  failed to read the directory: %s", err)
+	}
+	if len(entries) == 0 {
+		return "  <empty>"
+	}
+
+	var lines []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		lines = append(lines, "  "+name)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// compactJSON returns the compact JSON string, the numbers are kept as is to avoid the precision loss
+func compactJSON(data []byte) (string, error) {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, bytes.TrimSpace(data)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// generateHash returns the hash naming the cached files of the input, e.g. the values or the repository URL
 func generateHash(input string) string {
-	const hashLen = 8
+	return truncatedHash(input, cacheHashLength)
+}
 
+// truncatedHash returns the MD5 hex hash of the input truncated to the length, zero length keeps the full hash
+func truncatedHash(input string, hashLen int) string {
 	hash := md5.Sum([]byte(input))
 	hashStr := hex.EncodeToString(hash[:])
 	if hashLen > 0 && hashLen < len(hashStr) {
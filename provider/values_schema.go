@@ -0,0 +1,445 @@
+package provider
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	valuesSchemaFile = "values.schema.json"
+	chartValuesFile  = "values.yaml"
+)
+
+// readChartFiles reads the given top-level files of the chart directory or the packaged chart, the missing files are skipped
+func readChartFiles(chartPath string, names ...string) (map[string][]byte, error) {
+	info, err := os.Stat(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("chart '%s' isn't available locally: %s", chartPath, err)
+	}
+
+	files := map[string][]byte{}
+	if info.IsDir() {
+		for _, name := range names {
+			data, err := os.ReadFile(filepath.Join(chartPath, name))
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the chart file '%s': %s", name, err)
+			}
+			files[name] = data
+		}
+		return files, nil
+	}
+
+	f, err := os.Open(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the chart archive: %s", err)
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the chart archive: %s", err)
+	}
+	defer gr.Close()
+
+	// The packaged chart has the '<chart>/<file>' layout
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the chart archive: %s", err)
+		}
+
+		parts := strings.Split(strings.TrimPrefix(header.Name, "./"), "/")
+		if len(parts) != 2 {
+			continue
+		}
+		for _, name := range names {
+			if parts[1] == name {
+				if files[name], err = io.ReadAll(tr); err != nil {
+					return nil, fmt.Errorf("failed to read the chart file '%s': %s", name, err)
+				}
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// mergeValues merges the values files in the order Helm does, the later values override the earlier ones and null removes the key
+func mergeValues(dst, src map[string]interface{}) map[string]interface{} {
+	for key, value := range src {
+		if value == nil {
+			delete(dst, key)
+			continue
+		}
+		srcMap, srcIsMap := value.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			dst[key] = mergeValues(dstMap, srcMap)
+		} else {
+			dst[key] = value
+		}
+	}
+	return dst
+}
+
+// loadValues parses the YAML values into the JSON compatible map
+func loadValues(data []byte) (map[string]interface{}, error) {
+	var values interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+	}
+	if values == nil {
+		return map[string]interface{}{}, nil
+	}
+
+	values = canonicalYAMLValue(values)
+	valuesMap, ok := values.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("values must be a map, got: %s", jsonType(values))
+	}
+	return valuesMap, nil
+}
+
+// validateChartValues validates the chart default values merged with the values files against the chart values schema,
+// the schema is optional so nothing is validated without it
+func validateChartValues(chartPath string, valuesFiles []string) error {
+	files, err := readChartFiles(chartPath, valuesSchemaFile, chartValuesFile)
+	if err != nil {
+		return err
+	}
+	schemaData, ok := files[valuesSchemaFile]
+	if !ok {
+		return nil
+	}
+
+	var schema interface{}
+	if err := json.Unmarshal(schemaData, &schema); err != nil {
+		return fmt.Errorf("failed to parse the chart '%s': %s", valuesSchemaFile, err)
+	}
+
+	values, err := loadValues(files[chartValuesFile])
+	if err != nil {
+		return fmt.Errorf("failed to parse the chart '%s': %s", chartValuesFile, err)
+	}
+	for _, vf := range valuesFiles {
+		data, err := os.ReadFile(vf)
+		if err != nil {
+			return fmt.Errorf("failed to read the values file: %s", err)
+		}
+		fileValues, err := loadValues(data)
+		if err != nil {
+			return fmt.Errorf("failed to parse the values file '%s': %s", vf, err)
+		}
+		values = mergeValues(values, fileValues)
+	}
+
+	v := schemaValidator{root: schema}
+	v.validate(schema, values, "")
+	if len(v.errors) > 0 {
+		return fmt.Errorf("values don't meet the chart '%s':\n- %s", valuesSchemaFile, strings.Join(v.errors, "\n- "))
+	}
+	return nil
+}
+
+// schemaValidator validates the values against the JSON Schema subset used by the charts,
+// the unsupported keywords and the remote references are ignored
+type schemaValidator struct {
+	root   interface{}
+	errors []string
+}
+
+func (v *schemaValidator) errorf(path, format string, args ...interface{}) {
+	if path == "" {
+		path = "(root)"
+	}
+	v.errors = append(v.errors, fmt.Sprintf("%s: %s", path, fmt.Sprintf(format, args...)))
+}
+
+// valid reports whether the value meets the schema without collecting the errors
+func (v *schemaValidator) valid(schema, value interface{}) bool {
+	sub := schemaValidator{root: v.root}
+	sub.validate(schema, value, "")
+	return len(sub.errors) == 0
+}
+
+func (v *schemaValidator) validate(schema, value interface{}, path string) {
+	s, ok := schema.(map[string]interface{})
+	if !ok {
+		if allowed, ok := schema.(bool); ok && !allowed {
+			v.errorf(path, "value isn't allowed")
+		}
+		return
+	}
+
+	if ref, ok := s["$ref"].(string); ok {
+		if resolved, ok := v.resolveRef(ref); ok {
+			v.validate(resolved, value, path)
+		}
+	}
+
+	if types, ok := s["type"]; ok && !typeMatches(types, value) {
+		v.errorf(path, "expected %s, got %s", typeNames(types), jsonType(value))
+		return
+	}
+	if enum, ok := s["enum"].([]interface{}); ok && !containsValue(enum, value) {
+		v.errorf(path, "value must be one of %s", jsonString(enum))
+	}
+	if constValue, ok := s["const"]; ok && !equalValues(constValue, value) {
+		v.errorf(path, "value must be %s", jsonString(constValue))
+	}
+
+	for _, sub := range schemaList(s["allOf"]) {
+		v.validate(sub, value, path)
+	}
+	if anyOf := schemaList(s["anyOf"]); len(anyOf) > 0 {
+		matched := false
+		for _, sub := range anyOf {
+			if v.valid(sub, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.errorf(path, "value must match at least one schema of 'anyOf'")
+		}
+	}
+	if oneOf := schemaList(s["oneOf"]); len(oneOf) > 0 {
+		matched := 0
+		for _, sub := range oneOf {
+			if v.valid(sub, value) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			v.errorf(path, "value must match exactly one schema of 'oneOf', matched: %d", matched)
+		}
+	}
+	if not, ok := s["not"]; ok && v.valid(not, value) {
+		v.errorf(path, "value must not match the schema of 'not'")
+	}
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		v.validateObject(s, val, path)
+	case []interface{}:
+		v.validateArray(s, val, path)
+	case string:
+		if min, ok := s["minLength"].(float64); ok && float64(len([]rune(val))) < min {
+			v.errorf(path, "length must be greater than or equal to %v", min)
+		}
+		if max, ok := s["maxLength"].(float64); ok && float64(len([]rune(val))) > max {
+			v.errorf(path, "length must be less than or equal to %v", max)
+		}
+		if pattern, ok := s["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(val) {
+				v.errorf(path, "value must match the pattern '%s'", pattern)
+			}
+		}
+	default:
+		if number, ok := toFloat(value); ok {
+			v.validateNumber(s, number, path)
+		}
+	}
+}
+
+func (v *schemaValidator) validateObject(s map[string]interface{}, value map[string]interface{}, path string) {
+	if required, ok := s["required"].([]interface{}); ok {
+		for _, r := range required {
+			if key, ok := r.(string); ok {
+				if _, ok := value[key]; !ok {
+					v.errorf(path, "'%s' is required", key)
+				}
+			}
+		}
+	}
+
+	properties, _ := s["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		keyPath := key
+		if path != "" {
+			keyPath = path + "." + key
+		}
+		if propertySchema, ok := properties[key]; ok {
+			v.validate(propertySchema, value[key], keyPath)
+		} else if additional, ok := s["additionalProperties"]; ok {
+			if allowed, ok := additional.(bool); ok && !allowed {
+				v.errorf(path, "additional property '%s' isn't allowed", key)
+			} else {
+				v.validate(additional, value[key], keyPath)
+			}
+		}
+	}
+}
+
+func (v *schemaValidator) validateArray(s map[string]interface{}, value []interface{}, path string) {
+	if min, ok := s["minItems"].(float64); ok && float64(len(value)) < min {
+		v.errorf(path, "array must have at least %v items", min)
+	}
+	if max, ok := s["maxItems"].(float64); ok && float64(len(value)) > max {
+		v.errorf(path, "array must have at most %v items", max)
+	}
+	if items, ok := s["items"]; ok {
+		for i, item := range value {
+			v.validate(items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+func (v *schemaValidator) validateNumber(s map[string]interface{}, value float64, path string) {
+	if min, ok := s["minimum"].(float64); ok && value < min {
+		v.errorf(path, "value must be greater than or equal to %v", min)
+	}
+	if max, ok := s["maximum"].(float64); ok && value > max {
+		v.errorf(path, "value must be less than or equal to %v", max)
+	}
+	if min, ok := s["exclusiveMinimum"].(float64); ok && value <= min {
+		v.errorf(path, "value must be greater than %v", min)
+	}
+	if max, ok := s["exclusiveMaximum"].(float64); ok && value >= max {
+		v.errorf(path, "value must be less than %v", max)
+	}
+}
+
+// resolveRef resolves the local JSON pointer reference, e.g. '#/definitions/image'
+func (v *schemaValidator) resolveRef(ref string) (interface{}, bool) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, false
+	}
+
+	current := v.root
+	for _, part := range strings.Split(strings.TrimPrefix(strings.TrimPrefix(ref, "#"), "/"), "/") {
+		if part == "" {
+			continue
+		}
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func schemaList(value interface{}) []interface{} {
+	list, _ := value.([]interface{})
+	return list
+}
+
+func typeMatches(types, value interface{}) bool {
+	switch t := types.(type) {
+	case string:
+		return jsonType(value) == t || (t == "number" && jsonType(value) == "integer")
+	case []interface{}:
+		for _, item := range t {
+			if typeMatches(item, value) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+func typeNames(types interface{}) string {
+	if list, ok := types.([]interface{}); ok {
+		var names []string
+		for _, t := range list {
+			names = append(names, fmt.Sprint(t))
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(types)
+}
+
+// jsonType returns the JSON Schema type of the value, the whole numbers are integers
+func jsonType(value interface{}) string {
+	switch val := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		if number, ok := toFloat(val); ok {
+			if number == math.Trunc(number) && !math.IsInf(number, 0) {
+				return "integer"
+			}
+			return "number"
+		}
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch val := value.(type) {
+	case int:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case uint64:
+		return float64(val), true
+	case float64:
+		return val, true
+	default:
+		return 0, false
+	}
+}
+
+// equalValues compares the values, the numbers are compared regardless of their Go type
+func equalValues(a, b interface{}) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func containsValue(list []interface{}, value interface{}) bool {
+	for _, item := range list {
+		if equalValues(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonString(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
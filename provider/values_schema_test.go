@@ -0,0 +1,186 @@
+package provider
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testValuesSchema = `{
+  "$schema": "https://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "required": ["image"],
+  "properties": {
+    "replicaCount": {"type": "integer", "minimum": 1},
+    "image": {"$ref": "#/definitions/image"},
+    "service": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "type": {"enum": ["ClusterIP", "NodePort", "LoadBalancer"]},
+        "port": {"type": "integer", "maximum": 65535}
+      }
+    },
+    "ingress": {
+      "type": "object",
+      "properties": {
+        "hosts": {"type": "array", "minItems": 1, "items": {"type": "string", "pattern": "^[a-z0-9.-]+$"}}
+      }
+    }
+  },
+  "definitions": {
+    "image": {
+      "type": "object",
+      "required": ["repository"],
+      "properties": {
+        "repository": {"type": "string", "minLength": 1},
+        "tag": {"type": ["string", "null"]}
+      }
+    }
+  }
+}`
+
+// valuesSchemaChart creates the chart fixture with the values schema
+func valuesSchemaChart(t *testing.T) string {
+	chartDir := filepath.Join(t.TempDir(), "nginx")
+	if err := os.MkdirAll(chartDir, os.ModePerm); err != nil {
+		t.Fatalf("failed to create chart directory: %v", err)
+	}
+	files := map[string]string{
+		"Chart.yaml":         "apiVersion: v2\nname: nginx\nversion: 1.0.0\n",
+		"values.yaml":        "replicaCount: 1\nimage:\n  repository: nginx\n  tag: null\nservice:\n  type: ClusterIP\n  port: 80\n",
+		"values.schema.json": testValuesSchema,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(chartDir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+	}
+	return chartDir
+}
+
+// valuesFile writes the values file to the temporary directory
+func valuesFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "values.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to create values file: %v", err)
+	}
+	return path
+}
+
+// TestValidateChartValues tests the validation of the merged values against the chart values schema
+func TestValidateChartValues(t *testing.T) {
+	chartDir := valuesSchemaChart(t)
+
+	valid := [][]string{
+		{""},
+		{"replicaCount: 3\nimage:\n  tag: '1.25'\n"},
+		{"service:\n  type: NodePort\n  port: 30080\ningress:\n  hosts: [example.com]\n"},
+		// The merged values are validated, so the later file fixes the earlier one
+		{"replicaCount: 0\n", "replicaCount: 2\n"},
+	}
+	for _, values := range valid {
+		var files []string
+		for _, content := range values {
+			files = append(files, valuesFile(t, content))
+		}
+		if err := validateChartValues(chartDir, files); err != nil {
+			t.Errorf("unexpected error for the values %q: %v", values, err)
+		}
+	}
+
+	invalid := []struct {
+		values   string
+		expected []string
+	}{
+		{"replicaCount: '3'\n", []string{"replicaCount: expected integer, got string"}},
+		{"replicaCount: 0\n", []string{"replicaCount: value must be greater than or equal to 1"}},
+		{"image: null\n", []string{"(root): 'image' is required"}},
+		{"image:\n  repository: ''\n  tag: 1\n", []string{"image.repository: length must be greater than or equal to 1", "image.tag: expected string or null, got integer"}},
+		{"service:\n  type: ExternalName\n  port: 70000\n  nodePort: 30080\n", []string{
+			`service.type: value must be one of ["ClusterIP","NodePort","LoadBalancer"]`,
+			"service.port: value must be less than or equal to 65535",
+			"service: additional property 'nodePort' isn't allowed",
+		}},
+		{"ingress:\n  hosts: []\n", []string{"ingress.hosts: array must have at least 1 items"}},
+		{"ingress:\n  hosts: [Example.com]\n", []string{"ingress.hosts[0]: value must match the pattern '^[a-z0-9.-]+$'"}},
+	}
+	for _, tt := range invalid {
+		err := validateChartValues(chartDir, []string{valuesFile(t, tt.values)})
+		if err == nil {
+			t.Errorf("expected the values %q to be invalid", tt.values)
+			continue
+		}
+		for _, expected := range tt.expected {
+			if !strings.Contains(err.Error(), expected) {
+				t.Errorf("expected %q in the error of the values %q, got: %v", expected, tt.values, err)
+			}
+		}
+	}
+
+	// The chart without the schema isn't validated
+	if err := os.Remove(filepath.Join(chartDir, valuesSchemaFile)); err != nil {
+		t.Fatalf("failed to remove the schema: %v", err)
+	}
+	if err := validateChartValues(chartDir, []string{valuesFile(t, "replicaCount: '3'\n")}); err != nil {
+		t.Errorf("unexpected error without the schema: %v", err)
+	}
+}
+
+// TestValidateChartValuesPackagedChart tests that the schema is read from the packaged chart
+func TestValidateChartValuesPackagedChart(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "nginx-1.0.0.tgz")
+	f, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatalf("failed to create chart archive: %v", err)
+	}
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	files := []struct{ name, content string }{
+		{"nginx/Chart.yaml", "apiVersion: v2\nname: nginx\nversion: 1.0.0\n"},
+		{"nginx/values.yaml", "image:\n  repository: nginx\n"},
+		{"nginx/values.schema.json", testValuesSchema},
+		{"nginx/charts/common/values.schema.json", `{"required": ["common"]}`},
+	}
+	for _, file := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: file.name, Mode: 0600, Size: int64(len(file.content))}); err != nil {
+			t.Fatalf("failed to write chart archive: %v", err)
+		}
+		if _, err := tw.Write([]byte(file.content)); err != nil {
+			t.Fatalf("failed to write chart archive: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to write chart archive: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("failed to write chart archive: %v", err)
+	}
+	f.Close()
+
+	if err := validateChartValues(archivePath, []string{valuesFile(t, "replicaCount: 2\n")}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := validateChartValues(archivePath, []string{valuesFile(t, "replicaCount: two\n")}); err == nil || !strings.Contains(err.Error(), "replicaCount: expected integer, got string") {
+		t.Errorf("expected the schema error, got: %v", err)
+	}
+}
+
+// TestMergeValues tests that the values are merged in the Helm order
+func TestMergeValues(t *testing.T) {
+	values := mergeValues(
+		map[string]interface{}{"image": map[string]interface{}{"repository": "nginx", "tag": "1.0"}, "replicaCount": 1},
+		map[string]interface{}{"image": map[string]interface{}{"tag": "2.0"}, "replicaCount": nil},
+	)
+
+	image := values["image"].(map[string]interface{})
+	if image["repository"] != "nginx" || image["tag"] != "2.0" {
+		t.Errorf("unexpected merged image: %v", image)
+	}
+	if _, ok := values["replicaCount"]; ok {
+		t.Errorf("expected null to remove the key: %v", values)
+	}
+}